@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amounts travel through the REST API as decimal strings ("12.50") and are
+// stored on the ledger as int64 minor units (paise). Every asset uses the same units.
+const (
+	amountDecimals = 2
+	amountScale    = 100
+)
+
+// Amount is a quantity of VAP, or of another ledger asset, in minor units
+type Amount int64
+
+// ParseAmount parses a decimal string such as "12.5" into minor units.
+// More than amountDecimals fractional digits is an error rather than being truncated.
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("amount is required")
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+
+	whole, frac, hasFrac := strings.Cut(digits, ".")
+	if whole == "" || (hasFrac && frac == "") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > amountDecimals {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", s, amountDecimals)
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/amountScale {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+	units *= amountScale
+
+	if frac != "" {
+		frac += strings.Repeat("0", amountDecimals-len(frac))
+		fracUnits, _ := strconv.ParseInt(frac, 10, 64)
+		if units > math.MaxInt64-fracUnits {
+			return 0, fmt.Errorf("amount %q is out of range", s)
+		}
+		units += fracUnits
+	}
+
+	if negative {
+		units = -units
+	}
+	return Amount(units), nil
+}
+
+// String formats the amount as a decimal string with amountDecimals places
+func (a Amount) String() string {
+	sign := ""
+	u := uint64(a)
+	if a < 0 {
+		sign = "-"
+		u = uint64(-(a + 1)) + 1
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, u/amountScale, amountDecimals, u%amountScale)
+}
+
+// MarshalJSON renders the amount as a decimal string
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts a decimal string ("12.50") or a bare JSON number (12.5).
+// Numbers are parsed from their literal text, so no float rounding takes place.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var s string
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Positive reports an error unless the amount is greater than zero
+func (a Amount) Positive() error {
+	if a <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	return nil
+}
+
+// Units returns the minor-unit representation passed to the chaincode
+func (a Amount) Units() string {
+	return strconv.FormatInt(int64(a), 10)
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"vapcoin-backend/blockchain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// batchChunkBytes caps the encoded lines of one BatchTransfer call. Every
+	// line writes a record of its own, so the write set grows with the payload
+	// and this keeps each proposal well within the endorsement size limits.
+	batchChunkBytes = 32 << 10
+	// batchChunkLines is the chaincode's limit on lines per BatchTransfer
+	batchChunkLines = 200
+	// maxBatchIDLength leaves room for the chunk suffix within the chaincode's
+	// 64-character idempotency keys
+	maxBatchIDLength = 56
+)
+
+// BatchTransferLine is one recipient of a batch transfer
+type BatchTransferLine struct {
+	To     string `json:"to"`
+	Amount Amount `json:"amount"`
+}
+
+// BatchTransferRequest is the JSON form of a batch transfer. From defaults to
+// the caller's wallet. With ContinueOnError, chunks after a failed one are
+// still submitted.
+type BatchTransferRequest struct {
+	From            string              `json:"from"`
+	Transfers       []BatchTransferLine `json:"transfers"`
+	ContinueOnError bool                `json:"continueOnError"`
+}
+
+// batchItem is the chaincode's BatchTransferItem
+type batchItem struct {
+	To     string `json:"to"`
+	Amount int64  `json:"amount"`
+}
+
+// BatchChunkResult reports the outcome of one submitted chunk. Lines are
+// numbered from 1 in the order they were uploaded.
+type BatchChunkResult struct {
+	Chunk     int    `json:"chunk"`
+	FirstLine int    `json:"firstLine"`
+	LastLine  int    `json:"lastLine"`
+	Count     int    `json:"count"`
+	Total     Amount `json:"total"`
+	Status    string `json:"status"` // "committed", "failed" or "skipped"
+	BatchID   string `json:"batchId,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code,omitempty"`
+}
+
+// batchTransfer distributes coins from one wallet to many. The list is sent
+// as JSON, or as CSV ("to,amount" per line, optional header) with the source
+// wallet in the "from" query parameter and continueOnError as a query flag.
+// It is split into chunks by encoded size, each committed atomically; a failed
+// chunk does not undo the chunks before it, and the chunks after it are
+// skipped unless continueOnError is set.
+//
+// The Idempotency-Key header names the batch, and a random batch ID is used
+// and returned when it is absent. Chunk n is submitted with the key
+// "<batchId>-<n>", so retrying a partially committed upload with the same
+// batch ID and lines replays the committed chunks instead of paying them again.
+func batchTransfer(c *gin.Context) {
+	var req BatchTransferRequest
+	if strings.HasPrefix(c.ContentType(), "text/csv") {
+		lines, err := parseBatchCSV(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV: " + err.Error()})
+			return
+		}
+		req = BatchTransferRequest{From: c.Query("from"), Transfers: lines, ContinueOnError: c.Query("continueOnError") == "true"}
+	} else if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if req.From == "" {
+		req.From = c.GetString("walletId")
+	}
+	if len(req.Transfers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No transfers given"})
+		return
+	}
+	for i, line := range req.Transfers {
+		if line.To == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("line %d: recipient is required", i+1)})
+			return
+		}
+		if err := line.Amount.Positive(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("line %d: %v", i+1, err)})
+			return
+		}
+	}
+
+	batchID := c.GetHeader(idempotencyHeader)
+	if len(batchID) > maxBatchIDLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be at most %d characters", idempotencyHeader, maxBatchIDLength)})
+		return
+	}
+	if batchID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate a batch ID: " + err.Error()})
+			return
+		}
+		batchID = hex.EncodeToString(id)
+	}
+
+	var results []BatchChunkResult
+	failed := 0
+	start := 0
+	for _, chunk := range splitBatch(req.Transfers) {
+		result := BatchChunkResult{Status: "skipped"}
+		if failed == 0 || req.ContinueOnError {
+			key := fmt.Sprintf("%s-%d", batchID, len(results)+1)
+			result = submitBatchChunk(req.From, chunk, key)
+		}
+		result.Chunk = len(results) + 1
+		result.FirstLine = start + 1
+		result.LastLine = start + len(chunk)
+		result.Count = len(chunk)
+		for _, line := range chunk {
+			result.Total += line.Amount
+		}
+		if result.Status == "failed" {
+			failed++
+		}
+		results = append(results, result)
+		start += len(chunk)
+	}
+
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{
+		"from":    req.From,
+		"batchId": batchID,
+		"lines":   len(req.Transfers),
+		"chunks":  results,
+		"failed":  failed,
+		"success": failed == 0,
+	})
+}
+
+// splitBatch cuts lines into chunks of at most batchChunkLines lines whose
+// encoded items stay within batchChunkBytes. The split depends only on the
+// lines, so a retry of the same upload yields the same chunks.
+func splitBatch(lines []BatchTransferLine) [][]BatchTransferLine {
+	var chunks [][]BatchTransferLine
+	start, size := 0, 0
+	for i, line := range lines {
+		encoded, _ := json.Marshal(batchItem{To: line.To, Amount: int64(line.Amount)})
+		lineSize := len(encoded) + 1 // the separating comma
+		if i > start && (size+lineSize > batchChunkBytes || i-start == batchChunkLines) {
+			chunks = append(chunks, lines[start:i])
+			start, size = i, 0
+		}
+		size += lineSize
+	}
+	return append(chunks, lines[start:])
+}
+
+// submitBatchChunk sends one chunk to the chaincode's BatchTransfer under key
+func submitBatchChunk(from string, lines []BatchTransferLine, key string) BatchChunkResult {
+	items := make([]batchItem, len(lines))
+	for i, line := range lines {
+		items[i] = batchItem{To: line.To, Amount: int64(line.Amount)}
+	}
+
+	var result BatchChunkResult
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+
+	response, err := blockchain.Contract.SubmitTransaction("BatchTransfer", from, string(itemsJSON), key)
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		if ccErr, ok := blockchain.ParseError(err); ok {
+			result.Error = ccErr.Message
+			result.Code = ccErr.Code
+		}
+		return result
+	}
+
+	result.Status = "committed"
+	var records []*TransactionRecord
+	if err := json.Unmarshal(response, &records); err == nil && len(records) > 0 {
+		result.BatchID = records[0].BatchID
+	}
+	return result
+}
+
+// parseBatchCSV reads "to,amount" rows. A first row whose amount column is
+// not a number is treated as a header and skipped.
+func parseBatchCSV(r io.Reader) ([]BatchTransferLine, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var lines []BatchTransferLine
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		amount, err := ParseAmount(fields[1])
+		if err != nil {
+			if row == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("row %d: %v", row, err)
+		}
+		lines = append(lines, BatchTransferLine{To: strings.TrimSpace(fields[0]), Amount: amount})
+	}
+	return lines, nil
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"vapcoin-backend/blockchain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CloseWalletRequest names the wallet that receives the remaining balance,
+// such as a refund pool
+type CloseWalletRequest struct {
+	SweepTo string `json:"sweepTo" binding:"required"`
+}
+
+// BulkCloseRequest closes many wallets, e.g. a list of graduating students
+type BulkCloseRequest struct {
+	WalletIDs []string `json:"walletIds" binding:"required"`
+	SweepTo   string   `json:"sweepTo" binding:"required"`
+}
+
+// WalletClosureOutcome reports the closure of one wallet in a bulk close
+type WalletClosureOutcome struct {
+	WalletID string `json:"walletId"`
+	Status   string `json:"status"` // "closed" or "failed"
+	Total    Amount `json:"total"`
+	Records  int    `json:"records"`
+	Error    string `json:"error,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+func closeWallet(c *gin.Context) {
+	var req CloseWalletRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	result, err := submitCloseWallet(c.Param("id"), req.SweepTo)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// closeWallets closes each listed wallet in its own transaction, so one
+// failure does not stop the others
+func closeWallets(c *gin.Context) {
+	var req BulkCloseRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if len(req.WalletIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No wallets given"})
+		return
+	}
+
+	outcomes := make([]WalletClosureOutcome, 0, len(req.WalletIDs))
+	failed := 0
+	for _, id := range req.WalletIDs {
+		outcome := WalletClosureOutcome{WalletID: id}
+		result, err := submitCloseWallet(id, req.SweepTo)
+		if err != nil {
+			outcome.Status = "failed"
+			outcome.Error = err.Error()
+			if ccErr, ok := blockchain.ParseError(err); ok {
+				outcome.Error = ccErr.Message
+				outcome.Code = ccErr.Code
+			}
+			failed++
+		} else {
+			outcome.Status = "closed"
+			outcome.Total = Amount(result.Total)
+			outcome.Records = len(result.Records)
+		}
+		outcomes = append(outcomes, outcome)
+	}
+
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{
+		"sweepTo": req.SweepTo,
+		"wallets": outcomes,
+		"failed":  failed,
+		"success": failed == 0,
+	})
+}
+
+func submitCloseWallet(id string, sweepTo string) (*ClosureResult, error) {
+	response, err := blockchain.Contract.SubmitTransaction("CloseWallet", id, sweepTo)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ClosureResult
+	if err := json.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"vapcoin-backend/blockchain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chaincodeErrorStatus maps chaincode error codes to HTTP statuses
+var chaincodeErrorStatus = map[string]int{
+	blockchain.ErrCodeUnauthorized:        http.StatusUnauthorized,
+	blockchain.ErrCodeForbidden:           http.StatusForbidden,
+	blockchain.ErrCodeWalletFrozen:        http.StatusLocked,
+	blockchain.ErrCodeWalletClosed:        http.StatusConflict,
+	blockchain.ErrCodeLimitExceeded:       http.StatusUnprocessableEntity,
+	blockchain.ErrCodeNotFound:            http.StatusNotFound,
+	blockchain.ErrCodeRequestExpired:      http.StatusGone,
+	blockchain.ErrCodeRequestNotOpen:      http.StatusConflict,
+	blockchain.ErrCodeRefundExceeded:      http.StatusUnprocessableEntity,
+	blockchain.ErrCodeHoldNotActive:       http.StatusConflict,
+	blockchain.ErrCodeAllowanceExceeded:   http.StatusUnprocessableEntity,
+	blockchain.ErrCodeTransferNotAllowed:  http.StatusForbidden,
+	blockchain.ErrCodeIdempotencyConflict: http.StatusUnprocessableEntity,
+	blockchain.ErrCodeProposalNotOpen:     http.StatusConflict,
+}
+
+// respondChaincodeError writes a chaincode failure using the status matching
+// its error code, falling back to 500 for untyped errors.
+func respondChaincodeError(c *gin.Context, err error) {
+	if ccErr, ok := blockchain.ParseError(err); ok {
+		if code, known := chaincodeErrorStatus[ccErr.Code]; known {
+			c.JSON(code, gin.H{"error": ccErr.Message, "code": ccErr.Code})
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"vapcoin-backend/blockchain"
+	"vapcoin-backend/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// idempotencyHeader lets clients retry /transfer and /mint safely: repeating
+	// a key returns the original transaction ID instead of moving funds again
+	idempotencyHeader = "Idempotency-Key"
+	// replayedHeader marks a response answered from an earlier transaction
+	replayedHeader = "Idempotent-Replayed"
+)
+
+// ledgerIdempotencyRecord mirrors the chaincode IdempotencyRecord
+type ledgerIdempotencyRecord struct {
+	Function    string `json:"function"`
+	RequestHash string `json:"requestHash"`
+	TxID        string `json:"txId"`
+}
+
+// submitIdempotent submits a Transfer or Mint, appending the request's
+// Idempotency-Key as the last chaincode argument, and returns the transaction
+// ID. A key the backend already completed is answered from the database with
+// an Idempotent-Replayed header. Otherwise the ledger checks the key, so a
+// retry after the backend lost track of a committed submission still returns
+// the original transaction, and of two concurrent duplicates the one that
+// loses the MVCC check returns the winner's. Reusing a key for a different
+// request fails with IDEMPOTENCY_CONFLICT.
+func submitIdempotent(c *gin.Context, scope string, req interface{}, name string, transient map[string][]byte, args ...string) (string, error) {
+	key := c.GetHeader(idempotencyHeader)
+	if key == "" {
+		result, err := blockchain.Contract.Submit(name, client.WithArguments(append(args, "")...), client.WithTransient(transient))
+		return string(result), err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(c.FullPath()+"\n"), body...))
+	requestHash := hex.EncodeToString(sum[:])
+
+	var existing db.IdempotencyKey
+	if result := db.DB.Where("scope = ? AND key = ?", scope, key).First(&existing); result.Error == nil {
+		if existing.RequestHash != requestHash {
+			return "", &blockchain.ChaincodeError{
+				Code:    blockchain.ErrCodeIdempotencyConflict,
+				Message: fmt.Sprintf("idempotency key %s was already used for a different request", key),
+			}
+		}
+		c.Header(replayedHeader, "true")
+		return existing.TxID, nil
+	}
+
+	result, err := blockchain.Contract.Submit(name, client.WithArguments(append(args, key)...), client.WithTransient(transient))
+	txID := string(result)
+	if err != nil {
+		var commitErr *client.CommitError
+		if !errors.As(err, &commitErr) || commitErr.Code != peer.TxValidationCode_MVCC_READ_CONFLICT {
+			return "", err
+		}
+		if txID, err = claimedTxID(scope, key, name, args, err); err != nil {
+			return "", err
+		}
+		c.Header(replayedHeader, "true")
+	}
+
+	row := db.IdempotencyKey{Scope: scope, Key: key, RequestHash: requestHash, TxID: txID}
+	if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+		log.Printf("Failed to record idempotency key %s: %v", key, err)
+	}
+	return txID, nil
+}
+
+// claimedTxID reads the ledger's claim on key after a submission failed the
+// MVCC check and returns the transaction that claimed it, provided it was the
+// same request. The chaincode hashes the function and its arguments without
+// the key. It returns conflictErr when the key is still unclaimed, since the
+// conflict then came from something else.
+func claimedTxID(scope string, key string, name string, args []string, conflictErr error) (string, error) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetIdempotencyRecord", scope, key)
+	if err != nil {
+		if ccErr, ok := blockchain.ParseError(err); ok && ccErr.Code == blockchain.ErrCodeNotFound {
+			return "", conflictErr
+		}
+		return "", err
+	}
+
+	var record ledgerIdempotencyRecord
+	if err := json.Unmarshal(result, &record); err != nil {
+		return "", fmt.Errorf("failed to parse chaincode response: %w", err)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(append([]string{name}, args...), "\x00")))
+	if record.Function != name || record.RequestHash != hex.EncodeToString(sum[:]) {
+		return "", &blockchain.ChaincodeError{
+			Code:    blockchain.ErrCodeIdempotencyConflict,
+			Message: fmt.Sprintf("idempotency key %s was already used for a different request", key),
+		}
+	}
+	return record.TxID, nil
+}
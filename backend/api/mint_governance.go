@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"vapcoin-backend/blockchain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultProposalTTL applies when a proposal is created without an expiry
+const defaultProposalTTL = 7 * 24 * time.Hour
+
+// MintApproversRequest registers the identities that approve mints. Approvers
+// are client identity IDs as the chaincode's GetCallerID returns them, which
+// network/mint_vote.sh prints for a given credential. The threshold must be
+// at least 2.
+type MintApproversRequest struct {
+	Approvers []string `json:"approvers" binding:"required"`
+	Threshold int      `json:"threshold" binding:"required"`
+}
+
+// ProposeMintRequest opens a mint proposal
+type ProposeMintRequest struct {
+	Amount    Amount `json:"amount" binding:"required"`
+	Asset     string `json:"asset"` // token symbol; empty means VAP
+	Reason    string `json:"reason" binding:"required"`
+	ExpiresAt int64  `json:"expiresAt"` // Unix seconds, defaults to a week from now
+}
+
+// ProposeApproversRequest opens a proposal to replace the approver registry
+type ProposeApproversRequest struct {
+	MintApproversRequest
+	Reason    string `json:"reason" binding:"required"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// RejectMintRequest explains a rejection; the reason may be empty
+type RejectMintRequest struct {
+	Reason string `json:"reason"`
+}
+
+func getMintGovernance(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetMintGovernance")
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var governance MintGovernance
+	if err := json.Unmarshal(result, &governance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, governance)
+}
+
+// setMintApprovers registers the first approvers. Once set, the registry
+// only changes through an approved approvers proposal.
+func setMintApprovers(c *gin.Context) {
+	var req MintApproversRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	approvers, err := json.Marshal(req.Approvers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid approvers"})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("SetMintApprovers", string(approvers), strconv.Itoa(req.Threshold))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var governance MintGovernance
+	if err := json.Unmarshal(result, &governance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, governance)
+}
+
+func proposeMint(c *gin.Context) {
+	var req ProposeMintRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Amount.Positive(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("ProposeMint", req.Amount.Units(), req.Asset, req.Reason, proposalExpiry(req.ExpiresAt))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	respondMintProposal(c, http.StatusCreated, result)
+}
+
+func proposeApprovers(c *gin.Context) {
+	var req ProposeApproversRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	approvers, err := json.Marshal(req.Approvers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid approvers"})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("ProposeApprovers", string(approvers), strconv.Itoa(req.Threshold), req.Reason, proposalExpiry(req.ExpiresAt))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	respondMintProposal(c, http.StatusCreated, result)
+}
+
+func getMintProposals(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetAllMintProposals")
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var proposals []*MintProposal
+	if err := json.Unmarshal(result, &proposals); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	if status := c.Query("status"); status != "" {
+		filtered := []*MintProposal{}
+		for _, proposal := range proposals {
+			if proposal.Status == status {
+				filtered = append(filtered, proposal)
+			}
+		}
+		proposals = filtered
+	}
+	c.JSON(http.StatusOK, proposals)
+}
+
+func getMintProposal(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetMintProposal", c.Param("id"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	respondMintProposal(c, http.StatusOK, result)
+}
+
+// approveMint votes as the backend's own identity, so however many admins use
+// it, the API casts at most one approval per proposal. The other approvers
+// vote with their own credentials through network/mint_vote.sh.
+func approveMint(c *gin.Context) {
+	result, err := blockchain.Contract.SubmitTransaction("ApproveMint", c.Param("id"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	respondMintProposal(c, http.StatusOK, result)
+}
+
+// rejectMint rejects as the backend's own identity, see approveMint
+func rejectMint(c *gin.Context) {
+	var req RejectMintRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("RejectMint", c.Param("id"), req.Reason)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	respondMintProposal(c, http.StatusOK, result)
+}
+
+// proposalExpiry formats the requested expiry, or the default one
+func proposalExpiry(expiresAt int64) string {
+	if expiresAt == 0 {
+		expiresAt = time.Now().Add(defaultProposalTTL).Unix()
+	}
+	return strconv.FormatInt(expiresAt, 10)
+}
+
+func respondMintProposal(c *gin.Context, status int, result []byte) {
+	var proposal MintProposal
+	if err := json.Unmarshal(result, &proposal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(status, proposal)
+}
@@ -0,0 +1,564 @@
+package api
+
+import "encoding/json"
+
+// TransactionRecord mirrors the chaincode record. Amount holds minor units
+// as returned by the ledger and is rendered as a decimal string in responses.
+type TransactionRecord struct {
+	TxID       string `json:"txId"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Amount     int64  `json:"amount"`
+	Timestamp  int64  `json:"timestamp"`
+	Type       string `json:"type"`
+	Reason     string `json:"reason,omitempty"`
+	RequestID  string `json:"requestId,omitempty"`
+	HoldID     string `json:"holdId,omitempty"`
+	BatchID    string `json:"batchId,omitempty"`
+	ScheduleID string `json:"scheduleId,omitempty"`
+	ExpiresAt  int64  `json:"expiresAt,omitempty"`
+	GrantTxID  string `json:"grantTxId,omitempty"`
+	Asset      string `json:"asset,omitempty"`
+	Spender    string `json:"spender,omitempty"`
+	Memo       string `json:"memo,omitempty"`
+	Category   string `json:"category,omitempty"`
+	Reference  string `json:"reference,omitempty"`
+	// DetailsHash is the hash of the private transfer details, see getTransferDetails
+	DetailsHash string `json:"detailsHash,omitempty"`
+
+	OriginalTxID   string `json:"originalTxId,omitempty"`
+	RefundedAmount int64  `json:"refundedAmount,omitempty"`
+	RefundStatus   string `json:"refundStatus,omitempty"`
+
+	Fee         int64  `json:"fee,omitempty"`
+	PaymentTxID string `json:"paymentTxId,omitempty"`
+}
+
+func (r TransactionRecord) MarshalJSON() ([]byte, error) {
+	type record TransactionRecord
+	return json.Marshal(struct {
+		record
+		Amount         Amount  `json:"amount"`
+		RefundedAmount *Amount `json:"refundedAmount,omitempty"`
+		Fee            *Amount `json:"fee,omitempty"`
+	}{record(r), Amount(r.Amount), optionalAmount(r.RefundedAmount), optionalAmount(r.Fee)})
+}
+
+// optionalAmount returns nil for zero so omitempty drops the field
+func optionalAmount(units int64) *Amount {
+	if units == 0 {
+		return nil
+	}
+	amount := Amount(units)
+	return &amount
+}
+
+// PaginatedResponse mirrors the chaincode paginated transaction response
+type PaginatedResponse struct {
+	Records      []*TransactionRecord `json:"records"`
+	Bookmark     string               `json:"bookmark"`
+	RecordsCount int                  `json:"recordsCount"`
+}
+
+// SpendingLimit mirrors the chaincode spending limit. Zero means unlimited.
+type SpendingLimit struct {
+	MaxPerTransaction     int64  `json:"maxPerTransaction"`
+	MaxPerDay             int64  `json:"maxPerDay"`
+	MaxTransactionsPerDay int64  `json:"maxTransactionsPerDay"`
+	Source                string `json:"source,omitempty"`
+}
+
+func (l SpendingLimit) MarshalJSON() ([]byte, error) {
+	type limit SpendingLimit
+	return json.Marshal(struct {
+		limit
+		MaxPerTransaction Amount `json:"maxPerTransaction"`
+		MaxPerDay         Amount `json:"maxPerDay"`
+	}{limit(l), Amount(l.MaxPerTransaction), Amount(l.MaxPerDay)})
+}
+
+// SpendingAllowance mirrors the chaincode allowance for the current rolling day.
+// Remaining values are -1 when unlimited and are rendered as null.
+type SpendingAllowance struct {
+	WalletID              string        `json:"walletId"`
+	Asset                 string        `json:"asset"`
+	Limit                 SpendingLimit `json:"limit"`
+	SpentToday            int64         `json:"spentToday"`
+	TransactionsToday     int64         `json:"transactionsToday"`
+	RemainingAmount       int64         `json:"remainingAmount"`
+	RemainingTransactions int64         `json:"remainingTransactions"`
+	WindowStart           int64         `json:"windowStart"`
+}
+
+func (a SpendingAllowance) MarshalJSON() ([]byte, error) {
+	type allowance SpendingAllowance
+	var remainingAmount *Amount
+	if a.RemainingAmount >= 0 {
+		remaining := Amount(a.RemainingAmount)
+		remainingAmount = &remaining
+	}
+	var remainingTransactions *int64
+	if a.RemainingTransactions >= 0 {
+		remainingTransactions = &a.RemainingTransactions
+	}
+	return json.Marshal(struct {
+		allowance
+		SpentToday            Amount  `json:"spentToday"`
+		RemainingAmount       *Amount `json:"remainingAmount"`
+		RemainingTransactions *int64  `json:"remainingTransactions"`
+	}{allowance(a), Amount(a.SpentToday), remainingAmount, remainingTransactions})
+}
+
+// PaymentRequest mirrors the chaincode merchant payment request
+type PaymentRequest struct {
+	ID          string `json:"id"`
+	MerchantID  string `json:"merchantId"`
+	Amount      int64  `json:"amount"`
+	Reference   string `json:"reference"`
+	Status      string `json:"status"`
+	CreatedAt   int64  `json:"createdAt"`
+	ExpiresAt   int64  `json:"expiresAt"`
+	PaidBy      string `json:"paidBy,omitempty"`
+	PaidAt      int64  `json:"paidAt,omitempty"`
+	PaymentTxID string `json:"paymentTxId,omitempty"`
+}
+
+func (r PaymentRequest) MarshalJSON() ([]byte, error) {
+	type request PaymentRequest
+	return json.Marshal(struct {
+		request
+		Amount Amount `json:"amount"`
+	}{request(r), Amount(r.Amount)})
+}
+
+// PaginatedPaymentRequests mirrors a chaincode page of payment requests
+type PaginatedPaymentRequests struct {
+	Requests     []*PaymentRequest `json:"requests"`
+	Bookmark     string            `json:"bookmark"`
+	RecordsCount int               `json:"recordsCount"`
+}
+
+// Hold mirrors the chaincode hold on a wallet's funds
+type Hold struct {
+	ID             string `json:"id"`
+	WalletID       string `json:"walletId"`
+	MerchantID     string `json:"merchantId"`
+	Amount         int64  `json:"amount"`
+	CapturedAmount int64  `json:"capturedAmount"`
+	Reference      string `json:"reference"`
+	Status         string `json:"status"`
+	CreatedAt      int64  `json:"createdAt"`
+	ExpiresAt      int64  `json:"expiresAt"`
+	ClosedAt       int64  `json:"closedAt,omitempty"`
+	CaptureTxID    string `json:"captureTxId,omitempty"`
+}
+
+func (h Hold) MarshalJSON() ([]byte, error) {
+	type hold Hold
+	return json.Marshal(struct {
+		hold
+		Amount         Amount `json:"amount"`
+		CapturedAmount Amount `json:"capturedAmount"`
+	}{hold(h), Amount(h.Amount), Amount(h.CapturedAmount)})
+}
+
+// BalanceDetails mirrors the chaincode split of total, held and available funds
+type BalanceDetails struct {
+	WalletID  string      `json:"walletId"`
+	Total     int64       `json:"total"`
+	Held      int64       `json:"held"`
+	Available int64       `json:"available"`
+	Expiring  int64       `json:"expiring"`
+	Expired   int64       `json:"expired"`
+	Grants    []*GrantLot `json:"grants,omitempty"`
+}
+
+func (d BalanceDetails) MarshalJSON() ([]byte, error) {
+	type details BalanceDetails
+	return json.Marshal(struct {
+		details
+		Total     Amount `json:"total"`
+		Held      Amount `json:"held"`
+		Available Amount `json:"available"`
+		Expiring  Amount `json:"expiring"`
+		Expired   Amount `json:"expired"`
+	}{details(d), Amount(d.Total), Amount(d.Held), Amount(d.Available), Amount(d.Expiring), Amount(d.Expired)})
+}
+
+// GrantLot mirrors the unspent part of an expiring chaincode grant
+type GrantLot struct {
+	TxID      string `json:"txId"`
+	Amount    int64  `json:"amount"`
+	ExpiresAt int64  `json:"expiresAt"`
+	Treasury  string `json:"treasury"`
+}
+
+func (l GrantLot) MarshalJSON() ([]byte, error) {
+	type lot GrantLot
+	return json.Marshal(struct {
+		lot
+		Amount Amount `json:"amount"`
+	}{lot(l), Amount(l.Amount)})
+}
+
+// Approval mirrors the chaincode allowance a wallet owner grants a spender
+type Approval struct {
+	Owner     string `json:"owner"`
+	Spender   string `json:"spender"`
+	Amount    int64  `json:"amount"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+func (a Approval) MarshalJSON() ([]byte, error) {
+	type approval Approval
+	return json.Marshal(struct {
+		approval
+		Amount Amount `json:"amount"`
+	}{approval(a), Amount(a.Amount)})
+}
+
+// Supply mirrors the chaincode supply totals
+type Supply struct {
+	Minted int64 `json:"minted"`
+	Burned int64 `json:"burned"`
+	Total  int64 `json:"total"`
+}
+
+func (s Supply) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Minted Amount `json:"minted"`
+		Burned Amount `json:"burned"`
+		Total  Amount `json:"total"`
+	}{Amount(s.Minted), Amount(s.Burned), Amount(s.Total)})
+}
+
+// Discrepancy mirrors one failed ledger invariant
+type Discrepancy struct {
+	Type     string `json:"type"`
+	Key      string `json:"key"`
+	Expected int64  `json:"expected"`
+	Actual   int64  `json:"actual"`
+	Message  string `json:"message"`
+}
+
+func (d Discrepancy) MarshalJSON() ([]byte, error) {
+	type discrepancy Discrepancy
+	return json.Marshal(struct {
+		discrepancy
+		Expected Amount `json:"expected"`
+		Actual   Amount `json:"actual"`
+	}{discrepancy(d), Amount(d.Expected), Amount(d.Actual)})
+}
+
+// LedgerReport mirrors the chaincode VerifyLedgerInvariants report
+type LedgerReport struct {
+	CheckedAt       int64          `json:"checkedAt"`
+	Supply          Supply         `json:"supply"`
+	WalletCount     int            `json:"walletCount"`
+	WalletTotal     int64          `json:"walletTotal"`
+	RecordCount     int            `json:"recordCount"`
+	MintedInRecords int64          `json:"mintedInRecords"`
+	BurnedInRecords int64          `json:"burnedInRecords"`
+	Consistent      bool           `json:"consistent"`
+	Discrepancies   []*Discrepancy `json:"discrepancies"`
+}
+
+func (r LedgerReport) MarshalJSON() ([]byte, error) {
+	type report LedgerReport
+	return json.Marshal(struct {
+		report
+		WalletTotal     Amount `json:"walletTotal"`
+		MintedInRecords Amount `json:"mintedInRecords"`
+		BurnedInRecords Amount `json:"burnedInRecords"`
+	}{report(r), Amount(r.WalletTotal), Amount(r.MintedInRecords), Amount(r.BurnedInRecords)})
+}
+
+// UserWallet mirrors the chaincode wallet
+type UserWallet struct {
+	ID       string `json:"id"`
+	Balance  int64  `json:"balance"`
+	Held     int64  `json:"held,omitempty"`
+	Type     string `json:"type"`
+	Category string `json:"category,omitempty"`
+	Cohort   string `json:"cohort,omitempty"`
+
+	DisplayName       string `json:"displayName,omitempty"`
+	Location          string `json:"location,omitempty"`
+	LogoRef           string `json:"logoRef,omitempty"`
+	Department        string `json:"department,omitempty"`
+	MetadataUpdatedAt int64  `json:"metadataUpdatedAt,omitempty"`
+
+	Status          string `json:"status,omitempty"`
+	StatusReason    string `json:"statusReason,omitempty"`
+	StatusNote      string `json:"statusNote,omitempty"`
+	StatusChangedBy string `json:"statusChangedBy,omitempty"`
+	StatusChangedAt int64  `json:"statusChangedAt,omitempty"`
+	DetailsHash     string `json:"detailsHash,omitempty"`
+
+	Grants []*GrantLot `json:"grants,omitempty"`
+}
+
+func (w UserWallet) MarshalJSON() ([]byte, error) {
+	type wallet UserWallet
+	return json.Marshal(struct {
+		wallet
+		Balance Amount `json:"balance"`
+		Held    Amount `json:"held"`
+	}{wallet(w), Amount(w.Balance), Amount(w.Held)})
+}
+
+// WalletProfile is the public part of a wallet shown to other users, such as
+// a student paying a merchant
+type WalletProfile struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Status      string `json:"status,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Location    string `json:"location,omitempty"`
+	LogoRef     string `json:"logoRef,omitempty"`
+	Department  string `json:"department,omitempty"`
+}
+
+// WalletHistoryEntry mirrors one committed change to a wallet
+type WalletHistoryEntry struct {
+	TxID         string      `json:"txId"`
+	Timestamp    int64       `json:"timestamp"`
+	IsDelete     bool        `json:"isDelete"`
+	Wallet       *UserWallet `json:"wallet,omitempty"`
+	BalanceDelta int64       `json:"balanceDelta"`
+}
+
+func (e WalletHistoryEntry) MarshalJSON() ([]byte, error) {
+	type entry WalletHistoryEntry
+	return json.Marshal(struct {
+		entry
+		BalanceDelta Amount `json:"balanceDelta"`
+	}{entry(e), Amount(e.BalanceDelta)})
+}
+
+// PaginatedWalletHistory mirrors the chaincode wallet history page
+type PaginatedWalletHistory struct {
+	Entries      []*WalletHistoryEntry `json:"entries"`
+	Bookmark     string                `json:"bookmark"`
+	RecordsCount int                   `json:"recordsCount"`
+}
+
+// FeePolicy mirrors the chaincode merchant fee policy
+type FeePolicy struct {
+	Category    string `json:"category"`
+	BasisPoints int64  `json:"basisPoints"`
+	Fixed       int64  `json:"fixed"`
+	Min         int64  `json:"min"`
+	Max         int64  `json:"max"`
+	TreasuryID  string `json:"treasuryId"`
+	UpdatedAt   int64  `json:"updatedAt"`
+}
+
+func (p FeePolicy) MarshalJSON() ([]byte, error) {
+	type policy FeePolicy
+	return json.Marshal(struct {
+		policy
+		Fixed Amount `json:"fixed"`
+		Min   Amount `json:"min"`
+		Max   Amount `json:"max"`
+	}{policy(p), Amount(p.Fixed), Amount(p.Min), Amount(p.Max)})
+}
+
+// TransferRule mirrors the chaincode transfer policy rule for a pair of wallet types
+type TransferRule struct {
+	SenderRole        string `json:"senderRole"`
+	ReceiverRole      string `json:"receiverRole"`
+	Allowed           bool   `json:"allowed"`
+	MaxPerTransaction int64  `json:"maxPerTransaction"`
+	UpdatedAt         int64  `json:"updatedAt"`
+}
+
+func (r TransferRule) MarshalJSON() ([]byte, error) {
+	type rule TransferRule
+	return json.Marshal(struct {
+		rule
+		MaxPerTransaction Amount `json:"maxPerTransaction"`
+	}{rule(r), Amount(r.MaxPerTransaction)})
+}
+
+// Schedule mirrors the chaincode recurring payment schedule
+type Schedule struct {
+	ID         string   `json:"id"`
+	Source     string   `json:"source"`
+	Recipients []string `json:"recipients,omitempty"`
+	Cohort     string   `json:"cohort,omitempty"`
+	Amount     int64    `json:"amount"`
+	Cadence    string   `json:"cadence"`
+	NextRun    int64    `json:"nextRun"`
+	LastRun    int64    `json:"lastRun,omitempty"`
+	RunCount   int      `json:"runCount"`
+	Status     string   `json:"status"`
+	CreatedAt  int64    `json:"createdAt"`
+}
+
+func (s Schedule) MarshalJSON() ([]byte, error) {
+	type schedule Schedule
+	return json.Marshal(struct {
+		schedule
+		Amount Amount `json:"amount"`
+	}{schedule(s), Amount(s.Amount)})
+}
+
+// ScheduleRun mirrors the chaincode record of one paid schedule period
+type ScheduleRun struct {
+	ScheduleID string   `json:"scheduleId"`
+	Period     int64    `json:"period"`
+	TxID       string   `json:"txId"`
+	ExecutedAt int64    `json:"executedAt"`
+	Paid       []string `json:"paid"`
+	Skipped    []string `json:"skipped,omitempty"`
+	Total      int64    `json:"total"`
+}
+
+func (r ScheduleRun) MarshalJSON() ([]byte, error) {
+	type run ScheduleRun
+	return json.Marshal(struct {
+		run
+		Total Amount `json:"total"`
+	}{run(r), Amount(r.Total)})
+}
+
+// ScheduleFailure mirrors a due schedule the chaincode could not pay
+type ScheduleFailure struct {
+	ScheduleID string `json:"scheduleId"`
+	Period     int64  `json:"period"`
+	Error      string `json:"error"`
+}
+
+// ScheduleExecution mirrors the outcome of ExecuteDueSchedules
+type ScheduleExecution struct {
+	Now      int64              `json:"now"`
+	Runs     []*ScheduleRun     `json:"runs"`
+	Failures []*ScheduleFailure `json:"failures"`
+	Pending  bool               `json:"pending"`
+}
+
+// SweepResult mirrors the outcome of SweepExpired
+type SweepResult struct {
+	Records []*TransactionRecord `json:"records"`
+	Total   int64                `json:"total"`
+	Pending bool                 `json:"pending"`
+}
+
+func (r SweepResult) MarshalJSON() ([]byte, error) {
+	type result SweepResult
+	return json.Marshal(struct {
+		result
+		Total Amount `json:"total"`
+	}{result(r), Amount(r.Total)})
+}
+
+// Token mirrors a chaincode token registry entry
+type Token struct {
+	Symbol       string `json:"symbol"`
+	Name         string `json:"name"`
+	Decimals     int    `json:"decimals"`
+	Issuer       string `json:"issuer"`
+	Transferable bool   `json:"transferable"`
+	Supply       int64  `json:"supply"`
+	CreatedAt    int64  `json:"createdAt"`
+}
+
+func (t Token) MarshalJSON() ([]byte, error) {
+	type token Token
+	return json.Marshal(struct {
+		token
+		Supply Amount `json:"supply"`
+	}{token(t), Amount(t.Supply)})
+}
+
+// AssetBalance mirrors the balance of one asset in one wallet
+type AssetBalance struct {
+	WalletID string `json:"walletId"`
+	Asset    string `json:"asset"`
+	Balance  int64  `json:"balance"`
+}
+
+func (b AssetBalance) MarshalJSON() ([]byte, error) {
+	type balance AssetBalance
+	return json.Marshal(struct {
+		balance
+		Balance Amount `json:"balance"`
+	}{balance(b), Amount(b.Balance)})
+}
+
+// WalletDetails mirrors the owner details kept in the chaincode's private collection
+type WalletDetails struct {
+	WalletID      string `json:"walletId"`
+	FullName      string `json:"fullName,omitempty"`
+	Email         string `json:"email,omitempty"`
+	Phone         string `json:"phone,omitempty"`
+	StudentNumber string `json:"studentNumber,omitempty"`
+	UpdatedAt     int64  `json:"updatedAt"`
+	Salt          string `json:"salt"`
+}
+
+// PrivateTransferDetails mirrors the payment details kept in the chaincode's private collection
+type PrivateTransferDetails struct {
+	TxID string `json:"txId"`
+	Memo string `json:"memo,omitempty"`
+	Note string `json:"note,omitempty"`
+	Salt string `json:"salt"`
+}
+
+// ClosureResult mirrors the outcome of the chaincode's CloseWallet
+type ClosureResult struct {
+	Wallet  *UserWallet          `json:"wallet"`
+	Records []*TransactionRecord `json:"records"`
+	Total   int64                `json:"total"`
+}
+
+func (r ClosureResult) MarshalJSON() ([]byte, error) {
+	type result ClosureResult
+	return json.Marshal(struct {
+		result
+		Total Amount `json:"total"`
+	}{result(r), Amount(r.Total)})
+}
+
+// MintGovernance mirrors the chaincode registry of mint approvers
+type MintGovernance struct {
+	Approvers []string `json:"approvers"`
+	Threshold int      `json:"threshold"`
+	UpdatedAt int64    `json:"updatedAt"`
+}
+
+// ProposalVote mirrors one approver's vote on a mint proposal
+type ProposalVote struct {
+	Approver string `json:"approver"`
+	Reason   string `json:"reason,omitempty"`
+	At       int64  `json:"at"`
+}
+
+// MintProposal mirrors the chaincode mint proposal
+type MintProposal struct {
+	ID           string          `json:"id"`
+	Kind         string          `json:"kind"` // "mint" or "approvers"
+	Amount       int64           `json:"amount,omitempty"`
+	Asset        string          `json:"asset,omitempty"`
+	Approvers    []string        `json:"approvers,omitempty"`
+	Threshold    int             `json:"threshold,omitempty"`
+	Reason       string          `json:"reason"`
+	Proposer     string          `json:"proposer"`
+	Approvals    []*ProposalVote `json:"approvals"`
+	Rejections   []*ProposalVote `json:"rejections"`
+	Status       string          `json:"status"` // "pending", "approved", "executed", "rejected" or "expired"
+	CreatedAt    int64           `json:"createdAt"`
+	ExpiresAt    int64           `json:"expiresAt"`
+	ClosedAt     int64           `json:"closedAt,omitempty"`
+	ExecutedTxID string          `json:"executedTxId,omitempty"`
+}
+
+func (p MintProposal) MarshalJSON() ([]byte, error) {
+	type proposal MintProposal
+	return json.Marshal(struct {
+		proposal
+		Amount *Amount `json:"amount,omitempty"`
+	}{proposal(p), optionalAmount(p.Amount)})
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"vapcoin-backend/blockchain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Transient fields the chaincode reads private details from. Transient data
+// reaches the endorsing peers but is never written to a block.
+const (
+	walletDetailsTransientKey   = "walletDetails"
+	transferDetailsTransientKey = "transferDetails"
+	// saltTransientKey carries the random salt stored with the private value,
+	// so its public hash cannot be matched by hashing guesses
+	saltTransientKey = "salt"
+	saltLength       = 32
+)
+
+// WalletDetailsRequest holds the personal details of a wallet owner. They
+// are stored in a private collection; the ledger only keeps their hash.
+type WalletDetailsRequest struct {
+	FullName      string `json:"fullName"`
+	Email         string `json:"email"`
+	Phone         string `json:"phone"`
+	StudentNumber string `json:"studentNumber"`
+}
+
+func setWalletDetails(c *gin.Context) {
+	id := c.Param("id")
+	if !canAccessWallet(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own details"})
+		return
+	}
+
+	var req WalletDetailsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	transient, err := privateTransient(walletDetailsTransientKey, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid details"})
+		return
+	}
+
+	result, err := blockchain.Contract.Submit("SetWalletDetails", client.WithArguments(id), client.WithTransient(transient))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var wallet UserWallet
+	if err := json.Unmarshal(result, &wallet); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, wallet)
+}
+
+func getWalletDetails(c *gin.Context) {
+	id := c.Param("id")
+	if !canAccessWallet(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own details"})
+		return
+	}
+
+	result, err := blockchain.Contract.EvaluateTransaction("GetWalletDetails", id)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var details WalletDetails
+	if err := json.Unmarshal(result, &details); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, details)
+}
+
+// getTransferDetails returns the private memo and note of a payment to its
+// sender or receiver
+func getTransferDetails(c *gin.Context) {
+	txId := c.Param("txId")
+
+	result, err := blockchain.Contract.EvaluateTransaction("GetTransaction", txId)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	var record TransactionRecord
+	if err := json.Unmarshal(result, &record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transaction record"})
+		return
+	}
+	if !canAccessWallet(c, record.From) && !canAccessWallet(c, record.To) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the sender or receiver can view these details"})
+		return
+	}
+
+	result, err = blockchain.Contract.EvaluateTransaction("GetTransferDetails", txId)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var details PrivateTransferDetails
+	if err := json.Unmarshal(result, &details); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, details)
+}
+
+// canAccessWallet reports whether the caller owns the wallet or is an admin.
+// The backend signs as an admin identity, so the chaincode cannot check this.
+func canAccessWallet(c *gin.Context, walletID string) bool {
+	return c.GetString("role") == "admin" || walletID == c.GetString("walletId")
+}
+
+// privateTransient encodes v as the value of a transient field, together
+// with a fresh random salt
+func privateTransient(key string, v interface{}) (map[string][]byte, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return map[string][]byte{key: value, saltTransientKey: salt}, nil
+}
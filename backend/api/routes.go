@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 	"vapcoin-backend/blockchain"
 	"vapcoin-backend/db"
 
@@ -21,12 +23,93 @@ func SetupRoutes(r *gin.Engine) {
 	protected.Use(AuthMiddleware())
 	{
 		protected.GET("/balance/:id", getBalance)
+		protected.GET("/balance/:id/details", getBalanceDetails)
+		protected.GET("/balances/:id", getBalances)
+		protected.GET("/tokens", getTokens)
+		protected.GET("/tokens/:symbol", getToken)
 		protected.POST("/transfer", transfer)
 		protected.GET("/history/:id", getHistory)
 		protected.GET("/transactions", getAllTransactions)
-		protected.POST("/mint", mint)
-		protected.GET("/backup", backup)
-		protected.POST("/restore", restore)
+		protected.GET("/transactions/search", searchTransactions)
+		protected.GET("/transactions/reference/:reference", getTransactionsByReference)
+		protected.GET("/supply", getSupply)
+		protected.GET("/wallets/:id/allowance", getAllowance)
+		protected.GET("/wallets/:id", getWallet)
+		protected.PATCH("/wallets/:id/metadata", updateWalletMetadata)
+		protected.GET("/wallets/:id/history", getWalletHistory)
+		protected.GET("/wallets/:id/details", getWalletDetails)
+		protected.PUT("/wallets/:id/details", setWalletDetails)
+		protected.GET("/transactions/:txId/details", getTransferDetails)
+		protected.GET("/payment-requests/:requestId", getPaymentRequest)
+		protected.POST("/payment-requests/:requestId/pay", payPaymentRequest)
+		protected.POST("/holds", placeHold)
+		protected.GET("/holds/:holdId", getHold)
+		protected.POST("/approvals", approve)
+		protected.GET("/approvals/:owner/:spender", getApproval)
+		protected.POST("/transfer-from", transferFrom)
+	}
+
+	// Admin Routes
+	admin := protected.Group("/")
+	admin.Use(RequireRole("admin"))
+	{
+		admin.POST("/mint", mint)
+		admin.GET("/mint-governance", getMintGovernance)
+		admin.PUT("/mint-governance", setMintApprovers)
+		admin.POST("/mint-proposals", proposeMint)
+		admin.GET("/mint-proposals", getMintProposals)
+		admin.POST("/mint-proposals/approvers", proposeApprovers)
+		admin.GET("/mint-proposals/:id", getMintProposal)
+		admin.POST("/mint-proposals/:id/approve", approveMint)
+		admin.POST("/mint-proposals/:id/reject", rejectMint)
+		admin.POST("/mint-proposals/:id/execute", mint)
+		admin.POST("/batch-transfer", batchTransfer)
+		admin.GET("/backup", backup)
+		admin.GET("/ledger/verify", verifyLedger)
+		admin.POST("/restore", restore)
+		admin.POST("/wallets/:id/freeze", freezeWallet)
+		admin.POST("/wallets/:id/unfreeze", unfreezeWallet)
+		admin.POST("/wallets/:id/close", closeWallet)
+		admin.POST("/wallets/close", closeWallets)
+		admin.PUT("/limits/roles/:role", setRoleLimit)
+		admin.PUT("/wallets/:id/limits", setWalletLimit)
+		admin.DELETE("/wallets/:id/limits", clearWalletLimit)
+		admin.GET("/fees/:category", getFeePolicy)
+		admin.PUT("/fees/:category", setFeePolicy)
+		admin.DELETE("/fees/:category", clearFeePolicy)
+		admin.PUT("/wallets/:id/category", setMerchantCategory)
+		admin.PUT("/wallets/:id/cohort", setWalletCohort)
+		admin.GET("/transfer-policy", getTransferPolicy)
+		admin.PUT("/transfer-policy/:senderRole/:receiverRole", setTransferRule)
+		admin.DELETE("/transfer-policy/:senderRole/:receiverRole", deleteTransferRule)
+		admin.POST("/schedules", createSchedule)
+		admin.GET("/schedules", getSchedules)
+		admin.POST("/schedules/execute", executeSchedules)
+		admin.GET("/schedules/:id", getSchedule)
+		admin.DELETE("/schedules/:id", cancelSchedule)
+		admin.GET("/schedules/:id/runs", getScheduleRuns)
+		admin.POST("/grants", grant)
+		admin.POST("/grants/sweep", sweepExpiredGrants)
+		admin.POST("/tokens", registerToken)
+	}
+
+	// Admin & Merchant Routes
+	settlement := protected.Group("/")
+	settlement.Use(RequireRole("admin", "merchant"))
+	{
+		settlement.POST("/burn", burn)
+	}
+
+	// Merchant Routes
+	merchant := protected.Group("/")
+	merchant.Use(RequireRole("merchant"))
+	{
+		merchant.POST("/payment-requests", createPaymentRequest)
+		merchant.GET("/payment-requests", listPaymentRequests)
+		merchant.POST("/payment-requests/:requestId/cancel", cancelPaymentRequest)
+		merchant.POST("/transactions/:txId/refund", refund)
+		merchant.POST("/holds/:holdId/capture", captureHold)
+		merchant.POST("/holds/:holdId/release", releaseHold)
 	}
 }
 
@@ -43,6 +126,12 @@ func register(c *gin.Context) {
 		return
 	}
 
+	// Admin wallets are provisioned on the ledger, never through self-registration
+	if req.Role != "student" && req.Role != "merchant" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be student or merchant"})
+		return
+	}
+
 	// Check if user exists
 	var existingUser db.User
 	if result := db.DB.Where("username = ?", req.Username).First(&existingUser); result.Error == nil {
@@ -53,7 +142,7 @@ func register(c *gin.Context) {
 	// Create Wallet on Blockchain
 	_, err := blockchain.Contract.SubmitTransaction("CreateWallet", req.Username, req.Role)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create wallet on blockchain: " + err.Error()})
+		respondChaincodeError(c, fmt.Errorf("failed to create wallet on blockchain: %w", err))
 		return
 	}
 
@@ -106,7 +195,7 @@ func restore(c *gin.Context) {
 		// 2. Ensure Wallet Exists on Chain
 		// We try to create it. If it exists, chaincode returns error, which we can ignore or handle.
 		// Or we can check balance first.
-		_, err := blockchain.Contract.EvaluateTransaction("GetBalance", user.WalletID)
+		_, err := blockchain.Contract.EvaluateTransaction("GetBalance", user.WalletID, "")
 		if err != nil {
 			// Wallet likely doesn't exist (or other error). Try creating.
 			_, err := blockchain.Contract.SubmitTransaction("CreateWallet", user.WalletID, user.Role)
@@ -157,37 +246,79 @@ func login(c *gin.Context) {
 
 func getBalance(c *gin.Context) {
 	id := c.Param("id")
+	asset := c.Query("asset")
 
 	// Call Blockchain
-	result, err := blockchain.Contract.EvaluateTransaction("GetBalance", id)
+	result, err := blockchain.Contract.EvaluateTransaction("GetBalance", id, asset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var balance Amount
+	if err := json.Unmarshal(result, (*int64)(&balance)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"balance": string(result)})
+	if asset == "" {
+		c.JSON(http.StatusOK, gin.H{"balance": balance})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"balance": balance, "asset": asset})
 }
 
 type TransferRequest struct {
-	From   string  `json:"from"`
-	To     string  `json:"to"`
-	Amount float64 `json:"amount"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount Amount `json:"amount"`
+
+	// Optional details, validated by the chaincode. The memo and note are
+	// kept in a private collection; category and reference stay public.
+	Memo      string `json:"memo"`
+	Note      string `json:"note"`
+	Category  string `json:"category"`
+	Reference string `json:"reference"`
+
+	// Asset is the token symbol; empty means VAP
+	Asset string `json:"asset"`
 }
 
 func transfer(c *gin.Context) {
 	var req TransferRequest
 	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Amount.Positive(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	result, err := blockchain.Contract.SubmitTransaction("Transfer", req.From, req.To, fmt.Sprintf("%f", req.Amount))
+	// The backend signs as an admin identity, so the chaincode cannot tell
+	// users apart; only admins may move funds out of someone else's wallet.
+	if c.GetString("role") != "admin" && req.From != c.GetString("walletId") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only transfer from your own wallet"})
+		return
+	}
+
+	var transient map[string][]byte
+	if req.Memo != "" || req.Note != "" {
+		var err error
+		transient, err = privateTransient(transferDetailsTransientKey, PrivateTransferDetails{Memo: req.Memo, Note: req.Note})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid details"})
+			return
+		}
+	}
+
+	txID, err := submitIdempotent(c, req.From, req, "Transfer", transient, req.From, req.To, req.Amount.Units(), req.Category, req.Reference, req.Asset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondChaincodeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"txId": string(result)})
+	c.JSON(http.StatusOK, gin.H{"txId": txID})
 }
 
 func getHistory(c *gin.Context) {
@@ -195,13 +326,13 @@ func getHistory(c *gin.Context) {
 	pageSizeStr := c.DefaultQuery("pageSize", "10")
 	bookmark := c.DefaultQuery("bookmark", "")
 
-	result, err := blockchain.Contract.EvaluateTransaction("GetPaginatedTransactions", pageSizeStr, bookmark, id)
+	result, err := blockchain.Contract.EvaluateTransaction("GetPaginatedTransactions", pageSizeStr, bookmark, id, c.Query("asset"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondChaincodeError(c, err)
 		return
 	}
 
-	var resp map[string]interface{}
+	var resp PaginatedResponse
 	if err := json.Unmarshal(result, &resp); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
 		return
@@ -213,13 +344,13 @@ func getAllTransactions(c *gin.Context) {
 	pageSizeStr := c.DefaultQuery("pageSize", "10")
 	bookmark := c.DefaultQuery("bookmark", "")
 
-	result, err := blockchain.Contract.EvaluateTransaction("GetPaginatedTransactions", pageSizeStr, bookmark, "")
+	result, err := blockchain.Contract.EvaluateTransaction("GetPaginatedTransactions", pageSizeStr, bookmark, "", c.Query("asset"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondChaincodeError(c, err)
 		return
 	}
 
-	var resp map[string]interface{}
+	var resp PaginatedResponse
 	if err := json.Unmarshal(result, &resp); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
 		return
@@ -236,7 +367,7 @@ func getTransaction(c *gin.Context) {
 		return
 	}
 
-	var record map[string]interface{}
+	var record TransactionRecord
 	if err := json.Unmarshal(result, &record); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transaction record"})
 		return
@@ -245,21 +376,941 @@ func getTransaction(c *gin.Context) {
 	c.JSON(http.StatusOK, record)
 }
 
+// mint executes an approved mint proposal, see mint_governance.go. The
+// proposal comes from the body of POST /mint or the path of
+// POST /mint-proposals/:id/execute.
 func mint(c *gin.Context) {
 	type MintRequest struct {
-		Amount float64 `json:"amount"`
+		ProposalID string `json:"proposalId"`
+	}
+	req := MintRequest{ProposalID: c.Param("id")}
+	if req.ProposalID == "" {
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		if req.ProposalID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "proposalId is required"})
+			return
+		}
+	}
+
+	txID, err := submitIdempotent(c, "system", req, "Mint", nil, req.ProposalID)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
 	}
-	var req MintRequest
+
+	c.JSON(http.StatusOK, gin.H{"txId": txID})
+}
+
+type BurnRequest struct {
+	WalletID string `json:"walletId"`
+	Amount   Amount `json:"amount"`
+	Reason   string `json:"reason"`
+}
+
+// burn removes coins from circulation. Admins may burn from any wallet;
+// merchants redeem from their own wallet for cash settlement.
+func burn(c *gin.Context) {
+	var req BurnRequest
 	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Amount.Positive(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason is required"})
+		return
+	}
+
+	function := "Burn"
+	if c.GetString("role") == "merchant" {
+		function = "Redeem"
+		if req.WalletID == "" {
+			req.WalletID = c.GetString("walletId")
+		}
+		if req.WalletID != c.GetString("walletId") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only redeem from your own wallet"})
+			return
+		}
+	}
+	if req.WalletID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "walletId is required"})
 		return
 	}
 
-	result, err := blockchain.Contract.SubmitTransaction("Mint", fmt.Sprintf("%f", req.Amount))
+	result, err := blockchain.Contract.SubmitTransaction(function, req.WalletID, req.Amount.Units(), req.Reason)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondChaincodeError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"result": string(result)})
 }
+
+type WalletStatusRequest struct {
+	ReasonCode string `json:"reasonCode"`
+	Note       string `json:"note"`
+}
+
+func freezeWallet(c *gin.Context) {
+	id := c.Param("id")
+
+	var req WalletStatusRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	_, err := blockchain.Contract.SubmitTransaction("FreezeWallet", id, req.ReasonCode, req.Note)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wallet frozen"})
+}
+
+func unfreezeWallet(c *gin.Context) {
+	id := c.Param("id")
+
+	var req WalletStatusRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	_, err := blockchain.Contract.SubmitTransaction("UnfreezeWallet", id, req.Note)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wallet unfrozen"})
+}
+
+// SpendingLimitRequest sets spending limits on one asset. Zero values mean unlimited.
+type SpendingLimitRequest struct {
+	MaxPerTransaction     Amount `json:"maxPerTransaction"`
+	MaxPerDay             Amount `json:"maxPerDay"`
+	MaxTransactionsPerDay int64  `json:"maxTransactionsPerDay"`
+	Asset                 string `json:"asset"` // token symbol; empty means VAP
+}
+
+func (r SpendingLimitRequest) args() []string {
+	return []string{r.MaxPerTransaction.Units(), r.MaxPerDay.Units(), fmt.Sprintf("%d", r.MaxTransactionsPerDay), r.Asset}
+}
+
+func setRoleLimit(c *gin.Context) {
+	var req SpendingLimitRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	args := append([]string{c.Param("role")}, req.args()...)
+	_, err := blockchain.Contract.SubmitTransaction("SetRoleSpendingLimit", args...)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role spending limit updated"})
+}
+
+func setWalletLimit(c *gin.Context) {
+	var req SpendingLimitRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	args := append([]string{c.Param("id")}, req.args()...)
+	_, err := blockchain.Contract.SubmitTransaction("SetWalletSpendingLimit", args...)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wallet spending limit updated"})
+}
+
+func clearWalletLimit(c *gin.Context) {
+	_, err := blockchain.Contract.SubmitTransaction("ClearWalletSpendingLimit", c.Param("id"), c.Query("asset"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wallet spending limit cleared"})
+}
+
+func getAllowance(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetRemainingAllowance", c.Param("id"), c.Query("asset"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var allowance SpendingAllowance
+	if err := json.Unmarshal(result, &allowance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, allowance)
+}
+
+// defaultPaymentRequestTTL applies when a merchant does not specify an expiry
+const defaultPaymentRequestTTL = 15 * time.Minute
+
+type PaymentRequestRequest struct {
+	Amount    Amount `json:"amount"`
+	Reference string `json:"reference"`
+	ExpiresAt int64  `json:"expiresAt"` // Unix seconds, optional
+}
+
+func createPaymentRequest(c *gin.Context) {
+	var req PaymentRequestRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Amount.Positive(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresAt == 0 {
+		req.ExpiresAt = time.Now().Add(defaultPaymentRequestTTL).Unix()
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("CreatePaymentRequest", c.GetString("walletId"), req.Amount.Units(), req.Reference, strconv.FormatInt(req.ExpiresAt, 10))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondPaymentRequest(c, http.StatusCreated, result)
+}
+
+func getPaymentRequest(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetPaymentRequest", c.Param("requestId"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondPaymentRequest(c, http.StatusOK, result)
+}
+
+func payPaymentRequest(c *gin.Context) {
+	result, err := blockchain.Contract.SubmitTransaction("PayRequest", c.Param("requestId"), c.GetString("walletId"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondPaymentRequest(c, http.StatusOK, result)
+}
+
+func cancelPaymentRequest(c *gin.Context) {
+	// The chaincode only sees the backend identity, so check the merchant here
+	result, err := blockchain.Contract.EvaluateTransaction("GetPaymentRequest", c.Param("requestId"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	var request PaymentRequest
+	if err := json.Unmarshal(result, &request); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	if !canAccessWallet(c, request.MerchantID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the merchant who created this request can cancel it"})
+		return
+	}
+
+	result, err = blockchain.Contract.SubmitTransaction("CancelPaymentRequest", c.Param("requestId"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondPaymentRequest(c, http.StatusOK, result)
+}
+
+func listPaymentRequests(c *gin.Context) {
+	pageSizeStr := c.DefaultQuery("pageSize", "10")
+	bookmark := c.DefaultQuery("bookmark", "")
+
+	result, err := blockchain.Contract.EvaluateTransaction("GetPaymentRequestsByMerchant", c.GetString("walletId"), pageSizeStr, bookmark)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var resp PaginatedPaymentRequests
+	if err := json.Unmarshal(result, &resp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func respondPaymentRequest(c *gin.Context, status int, result []byte) {
+	var request PaymentRequest
+	if err := json.Unmarshal(result, &request); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(status, request)
+}
+
+type RefundRequest struct {
+	Amount Amount `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+// refund returns part or all of a payment received by the calling merchant
+func refund(c *gin.Context) {
+	txId := c.Param("txId")
+
+	var req RefundRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Amount.Positive(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The chaincode only sees the backend identity, so check the payee here
+	result, err := blockchain.Contract.EvaluateTransaction("GetTransaction", txId)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	var original TransactionRecord
+	if err := json.Unmarshal(result, &original); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transaction record"})
+		return
+	}
+	if original.To != c.GetString("walletId") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the original payee can refund this transaction"})
+		return
+	}
+
+	result, err = blockchain.Contract.SubmitTransaction("Refund", txId, req.Amount.Units(), req.Reason)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var record TransactionRecord
+	if err := json.Unmarshal(result, &record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transaction record"})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+func getBalanceDetails(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetBalanceDetails", c.Param("id"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var details BalanceDetails
+	if err := json.Unmarshal(result, &details); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, details)
+}
+
+// defaultHoldTTL applies when no expiry is given for a hold
+const defaultHoldTTL = 24 * time.Hour
+
+type PlaceHoldRequest struct {
+	MerchantID string `json:"merchantId"`
+	Amount     Amount `json:"amount"`
+	Reference  string `json:"reference"`
+	ExpiresAt  int64  `json:"expiresAt"` // Unix seconds, optional
+}
+
+// placeHold reserves funds in the caller's wallet for a merchant
+func placeHold(c *gin.Context) {
+	var req PlaceHoldRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Amount.Positive(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresAt == 0 {
+		req.ExpiresAt = time.Now().Add(defaultHoldTTL).Unix()
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("PlaceHold", c.GetString("walletId"), req.MerchantID, req.Amount.Units(), req.Reference, strconv.FormatInt(req.ExpiresAt, 10))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondHold(c, http.StatusCreated, result)
+}
+
+func getHold(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetHold", c.Param("holdId"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondHold(c, http.StatusOK, result)
+}
+
+type CaptureHoldRequest struct {
+	Amount Amount `json:"amount"`
+}
+
+func captureHold(c *gin.Context) {
+	var req CaptureHoldRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Amount.Positive(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireHoldMerchant(c) {
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("CaptureHold", c.Param("holdId"), req.Amount.Units())
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var record TransactionRecord
+	if err := json.Unmarshal(result, &record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transaction record"})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+func releaseHold(c *gin.Context) {
+	if !requireHoldMerchant(c) {
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("ReleaseHold", c.Param("holdId"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondHold(c, http.StatusOK, result)
+}
+
+// requireHoldMerchant checks that the calling merchant is the hold's beneficiary,
+// since the chaincode only sees the backend identity
+func requireHoldMerchant(c *gin.Context) bool {
+	result, err := blockchain.Contract.EvaluateTransaction("GetHold", c.Param("holdId"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return false
+	}
+
+	var hold Hold
+	if err := json.Unmarshal(result, &hold); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return false
+	}
+	if hold.MerchantID != c.GetString("walletId") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the merchant on the hold can settle it"})
+		return false
+	}
+	return true
+}
+
+func respondHold(c *gin.Context, status int, result []byte) {
+	var hold Hold
+	if err := json.Unmarshal(result, &hold); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(status, hold)
+}
+
+type ApproveRequest struct {
+	Spender string `json:"spender"`
+	Amount  Amount `json:"amount"` // zero revokes the allowance
+}
+
+// approve lets another wallet spend from the caller's wallet up to amount
+func approve(c *gin.Context) {
+	var req ApproveRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount cannot be negative"})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("Approve", c.GetString("walletId"), req.Spender, req.Amount.Units())
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var approval Approval
+	if err := json.Unmarshal(result, &approval); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, approval)
+}
+
+func getApproval(c *gin.Context) {
+	owner := c.Param("owner")
+	spender := c.Param("spender")
+
+	result, err := blockchain.Contract.EvaluateTransaction("Allowance", owner, spender)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var allowance Amount
+	if err := json.Unmarshal(result, (*int64)(&allowance)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"owner": owner, "spender": spender, "allowance": allowance})
+}
+
+type TransferFromRequest struct {
+	Owner  string `json:"owner"`
+	To     string `json:"to"`
+	Amount Amount `json:"amount"`
+}
+
+// transferFrom spends from an owner's wallet under an allowance granted to the caller
+func transferFrom(c *gin.Context) {
+	var req TransferFromRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Amount.Positive(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("TransferFrom", req.Owner, c.GetString("walletId"), req.To, req.Amount.Units())
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var record TransactionRecord
+	if err := json.Unmarshal(result, &record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transaction record"})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+func getSupply(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetSupply")
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var supply Supply
+	if err := json.Unmarshal(result, &supply); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, supply)
+}
+
+// verifyLedger runs the chaincode conservation check and returns its report
+func verifyLedger(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("VerifyLedgerInvariants")
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var report LedgerReport
+	if err := json.Unmarshal(result, &report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// TransactionFilter mirrors the chaincode filter for QueryTransactions.
+// Amounts are minor units.
+type TransactionFilter struct {
+	Type      string `json:"type,omitempty"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	Category  string `json:"category,omitempty"`
+	Reference string `json:"reference,omitempty"`
+	Asset     string `json:"asset,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
+	EndTime   int64  `json:"endTime,omitempty"`
+	MinAmount int64  `json:"minAmount,omitempty"`
+	MaxAmount int64  `json:"maxAmount,omitempty"`
+}
+
+// searchTransactions filters the ledger by type, parties, category, reference, asset, time range
+// (start/end, Unix seconds) and amount range (minAmount/maxAmount, decimal)
+func searchTransactions(c *gin.Context) {
+	filter := TransactionFilter{
+		Type:      c.Query("type"),
+		From:      c.Query("from"),
+		To:        c.Query("to"),
+		Category:  c.Query("category"),
+		Reference: c.Query("reference"),
+		Asset:     c.Query("asset"),
+	}
+
+	var err error
+	for param, target := range map[string]*int64{"start": &filter.StartTime, "end": &filter.EndTime} {
+		if value := c.Query(param); value != "" {
+			if *target, err = strconv.ParseInt(value, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s time %q", param, value)})
+				return
+			}
+		}
+	}
+	for param, target := range map[string]*int64{"minAmount": &filter.MinAmount, "maxAmount": &filter.MaxAmount} {
+		if value := c.Query(param); value != "" {
+			amount, err := ParseAmount(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			*target = int64(amount)
+		}
+	}
+
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	pageSizeStr := c.DefaultQuery("pageSize", "10")
+	bookmark := c.DefaultQuery("bookmark", "")
+
+	result, err := blockchain.Contract.EvaluateTransaction("QueryTransactions", string(filterJSON), pageSizeStr, bookmark)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var resp PaginatedResponse
+	if err := json.Unmarshal(result, &resp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// getWalletHistory returns the committed changes to a wallet, newest first
+func getWalletHistory(c *gin.Context) {
+	pageSizeStr := c.DefaultQuery("pageSize", "10")
+	bookmark := c.DefaultQuery("bookmark", "")
+
+	result, err := blockchain.Contract.EvaluateTransaction("GetHistory", c.Param("id"), pageSizeStr, bookmark)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var history PaginatedWalletHistory
+	if err := json.Unmarshal(result, &history); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// getTransactionsByReference lists the transactions carrying an external reference
+func getTransactionsByReference(c *gin.Context) {
+	pageSizeStr := c.DefaultQuery("pageSize", "10")
+	bookmark := c.DefaultQuery("bookmark", "")
+
+	result, err := blockchain.Contract.EvaluateTransaction("GetTransactionsByReference", c.Param("reference"), pageSizeStr, bookmark)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var resp PaginatedResponse
+	if err := json.Unmarshal(result, &resp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// FeePolicyRequest sets a merchant fee: basisPoints/10000 of the payment plus
+// fixed, clamped to [min, max]. A max of zero means no maximum.
+type FeePolicyRequest struct {
+	BasisPoints int64  `json:"basisPoints"`
+	Fixed       Amount `json:"fixed"`
+	Min         Amount `json:"min"`
+	Max         Amount `json:"max"`
+	TreasuryID  string `json:"treasuryId"`
+}
+
+func getFeePolicy(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetFeePolicy", c.Param("category"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondFeePolicy(c, result)
+}
+
+func setFeePolicy(c *gin.Context) {
+	var req FeePolicyRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("SetFeePolicy", c.Param("category"), strconv.FormatInt(req.BasisPoints, 10), req.Fixed.Units(), req.Min.Units(), req.Max.Units(), req.TreasuryID)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondFeePolicy(c, result)
+}
+
+func clearFeePolicy(c *gin.Context) {
+	_, err := blockchain.Contract.SubmitTransaction("ClearFeePolicy", c.Param("category"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Fee policy removed"})
+}
+
+func respondFeePolicy(c *gin.Context, result []byte) {
+	var policy FeePolicy
+	if err := json.Unmarshal(result, &policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// setMerchantCategory assigns the category that selects a merchant's fee policy
+func setMerchantCategory(c *gin.Context) {
+	var req struct {
+		Category string `json:"category"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	_, err := blockchain.Contract.SubmitTransaction("SetMerchantCategory", c.Param("id"), req.Category)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Merchant category updated"})
+}
+
+// TransferRuleRequest allows or denies payments between two wallet types, with
+// an optional per-transaction limit where zero means no limit
+type TransferRuleRequest struct {
+	Allowed           bool   `json:"allowed"`
+	MaxPerTransaction Amount `json:"maxPerTransaction"`
+}
+
+func getTransferPolicy(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetTransferPolicy")
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var rules []*TransferRule
+	if err := json.Unmarshal(result, &rules); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func setTransferRule(c *gin.Context) {
+	var req TransferRuleRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("SetTransferRule", c.Param("senderRole"), c.Param("receiverRole"), strconv.FormatBool(req.Allowed), req.MaxPerTransaction.Units())
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var rule TransferRule
+	if err := json.Unmarshal(result, &rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func deleteTransferRule(c *gin.Context) {
+	_, err := blockchain.Contract.SubmitTransaction("DeleteTransferRule", c.Param("senderRole"), c.Param("receiverRole"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer rule removed"})
+}
+
+// GrantRequest pays coins from a treasury wallet that expire unless spent by expiresAt
+type GrantRequest struct {
+	Treasury  string `json:"treasury" binding:"required"`
+	WalletID  string `json:"walletId" binding:"required"`
+	Amount    Amount `json:"amount" binding:"required"`
+	ExpiresAt int64  `json:"expiresAt" binding:"required"` // Unix seconds
+}
+
+func grant(c *gin.Context) {
+	var req GrantRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("Grant", req.Treasury, req.WalletID, req.Amount.Units(), strconv.FormatInt(req.ExpiresAt, 10))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var record TransactionRecord
+	if err := json.Unmarshal(result, &record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// sweepExpiredGrants returns expired grants to their treasuries now instead of on the keeper's next run
+func sweepExpiredGrants(c *gin.Context) {
+	result, err := sweepExpired()
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RegisterTokenRequest adds an asset to the ledger's token registry. Amounts of
+// every asset use VAP minor units; decimals (0 to 2) limits their precision.
+type RegisterTokenRequest struct {
+	Symbol       string `json:"symbol" binding:"required"`
+	Name         string `json:"name" binding:"required"`
+	Decimals     int    `json:"decimals"`
+	Issuer       string `json:"issuer" binding:"required"`
+	Transferable bool   `json:"transferable"`
+}
+
+func registerToken(c *gin.Context) {
+	var req RegisterTokenRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("RegisterToken", req.Symbol, req.Name, strconv.Itoa(req.Decimals), req.Issuer, strconv.FormatBool(req.Transferable))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondToken(c, http.StatusCreated, result)
+}
+
+func getTokens(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetAllTokens")
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var tokens []*Token
+	if err := json.Unmarshal(result, &tokens); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func getToken(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetToken", c.Param("symbol"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondToken(c, http.StatusOK, result)
+}
+
+func respondToken(c *gin.Context, status int, result []byte) {
+	var token Token
+	if err := json.Unmarshal(result, &token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(status, token)
+}
+
+// getBalances returns the balance of every asset a wallet holds
+func getBalances(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetBalances", c.Param("id"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var balances []*AssetBalance
+	if err := json.Unmarshal(result, &balances); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, balances)
+}
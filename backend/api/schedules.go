@@ -0,0 +1,270 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"vapcoin-backend/blockchain"
+	"vapcoin-backend/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// CreateScheduleRequest registers a recurring payment. Give either recipients
+// or cohort, whose active wallets are paid on each run; admins place wallets
+// in a cohort with PUT /wallets/:id/cohort.
+type CreateScheduleRequest struct {
+	ID         string   `json:"id" binding:"required"`
+	Source     string   `json:"source" binding:"required"`
+	Recipients []string `json:"recipients"`
+	Cohort     string   `json:"cohort"`
+	Amount     Amount   `json:"amount" binding:"required"`
+	Cadence    string   `json:"cadence" binding:"required"`  // "daily", "weekly" or "monthly"
+	FirstRun   int64    `json:"firstRun" binding:"required"` // Unix seconds
+}
+
+func createSchedule(c *gin.Context) {
+	var req CreateScheduleRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Recipients == nil {
+		req.Recipients = []string{}
+	}
+	recipients, err := json.Marshal(req.Recipients)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipients"})
+		return
+	}
+
+	result, err := blockchain.Contract.SubmitTransaction("CreateSchedule", req.ID, req.Source, string(recipients), req.Cohort, req.Amount.Units(), req.Cadence, strconv.FormatInt(req.FirstRun, 10))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondSchedule(c, http.StatusCreated, result)
+}
+
+func getSchedules(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetAllSchedules")
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var schedules []*Schedule
+	if err := json.Unmarshal(result, &schedules); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+func getSchedule(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetSchedule", c.Param("id"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondSchedule(c, http.StatusOK, result)
+}
+
+func cancelSchedule(c *gin.Context) {
+	result, err := blockchain.Contract.SubmitTransaction("CancelSchedule", c.Param("id"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	respondSchedule(c, http.StatusOK, result)
+}
+
+func getScheduleRuns(c *gin.Context) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetScheduleRuns", c.Param("id"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var runs []*ScheduleRun
+	if err := json.Unmarshal(result, &runs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// setWalletCohort places a wallet in the cohort that cohort schedules pay;
+// an empty cohort removes it
+func setWalletCohort(c *gin.Context) {
+	var req struct {
+		Cohort string `json:"cohort"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	_, err := blockchain.Contract.SubmitTransaction("SetWalletCohort", c.Param("id"), req.Cohort)
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wallet cohort updated"})
+}
+
+// executeSchedules runs the keeper once, outside its timer
+func executeSchedules(c *gin.Context) {
+	execution, err := executeDueSchedules(time.Now())
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, execution)
+}
+
+func respondSchedule(c *gin.Context, status int, result []byte) {
+	var schedule Schedule
+	if err := json.Unmarshal(result, &schedule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(status, schedule)
+}
+
+// keeperMu keeps the timer and manual runs from submitting at the same time;
+// the ledger would reject one of them as a conflict anyway
+var keeperMu sync.Mutex
+
+// StartScheduleKeeper pays due schedules and sweeps expired grants now and
+// then every interval. The ledger tracks which periods were paid, so
+// restarting the backend or running several keepers cannot pay a period twice.
+// Ticks with nothing due only evaluate, so they commit no transaction.
+func StartScheduleKeeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := executeDueSchedules(time.Now()); err != nil {
+				log.Printf("Schedule keeper: %v", err)
+			}
+			if _, err := sweepExpired(); err != nil {
+				log.Printf("Schedule keeper: failed to sweep expired grants: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// executeDueSchedules submits ExecuteDueSchedules until nothing due is left
+// pending, records the runs and returns the combined outcome
+func executeDueSchedules(now time.Time) (*ScheduleExecution, error) {
+	keeperMu.Lock()
+	defer keeperMu.Unlock()
+
+	combined := &ScheduleExecution{Now: now.Unix(), Runs: []*ScheduleRun{}, Failures: []*ScheduleFailure{}}
+	for {
+		result, err := submitWhenDue("ExecuteDueSchedules", func(result []byte) (bool, error) {
+			var execution ScheduleExecution
+			err := json.Unmarshal(result, &execution)
+			return len(execution.Runs) > 0 || execution.Pending, err
+		}, strconv.FormatInt(now.Unix(), 10))
+		if err != nil {
+			return nil, err
+		}
+
+		var execution ScheduleExecution
+		if err := json.Unmarshal(result, &execution); err != nil {
+			return nil, fmt.Errorf("failed to parse chaincode response: %w", err)
+		}
+		recordScheduleRuns(execution.Runs)
+		for _, failure := range execution.Failures {
+			log.Printf("Schedule keeper: schedule %s period %d not paid: %s", failure.ScheduleID, failure.Period, failure.Error)
+		}
+
+		combined.Runs = append(combined.Runs, execution.Runs...)
+		combined.Failures = execution.Failures
+		if !execution.Pending {
+			return combined, nil
+		}
+	}
+}
+
+// recordScheduleRuns stores the runs in the database, ignoring runs already stored
+func recordScheduleRuns(runs []*ScheduleRun) {
+	if len(runs) == 0 {
+		return
+	}
+
+	rows := make([]db.ScheduleRun, 0, len(runs))
+	for _, run := range runs {
+		rows = append(rows, db.ScheduleRun{
+			ScheduleID: run.ScheduleID,
+			Period:     run.Period,
+			TxID:       run.TxID,
+			ExecutedAt: time.Unix(run.ExecutedAt, 0),
+			Recipients: len(run.Paid),
+			Total:      run.Total,
+		})
+	}
+	if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		log.Printf("Schedule keeper: failed to record runs: %v", err)
+	}
+}
+
+// sweepExpired submits SweepExpired until no expired lot is left pending and
+// returns the combined outcome
+func sweepExpired() (*SweepResult, error) {
+	keeperMu.Lock()
+	defer keeperMu.Unlock()
+
+	combined := &SweepResult{Records: []*TransactionRecord{}}
+	for {
+		result, err := submitWhenDue("SweepExpired", func(result []byte) (bool, error) {
+			var sweep SweepResult
+			err := json.Unmarshal(result, &sweep)
+			return len(sweep.Records) > 0 || sweep.Pending, err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var sweep SweepResult
+		if err := json.Unmarshal(result, &sweep); err != nil {
+			return nil, fmt.Errorf("failed to parse chaincode response: %w", err)
+		}
+
+		combined.Records = append(combined.Records, sweep.Records...)
+		combined.Total += sweep.Total
+		if !sweep.Pending {
+			return combined, nil
+		}
+	}
+}
+
+// submitWhenDue evaluates a keeper transaction and submits it only when due
+// reports that the evaluated result has work in it. Otherwise it returns the
+// evaluated result, which changed nothing.
+func submitWhenDue(name string, due func(result []byte) (bool, error), args ...string) ([]byte, error) {
+	result, err := blockchain.Contract.EvaluateTransaction(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := due(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse chaincode response: %w", err)
+	}
+	if !ok {
+		return result, nil
+	}
+	return blockchain.Contract.SubmitTransaction(name, args...)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"vapcoin-backend/blockchain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateWalletMetadataRequest changes the public profile of a wallet. Omitted
+// fields keep their current value; an empty string clears a field. A
+// merchant's category is set with PUT /wallets/:id/category instead.
+type UpdateWalletMetadataRequest struct {
+	DisplayName *string `json:"displayName,omitempty"`
+	Location    *string `json:"location,omitempty"`
+	LogoRef     *string `json:"logoRef,omitempty"`
+	Department  *string `json:"department,omitempty"`
+}
+
+// getWallet returns the full wallet to its owner and admins, and the public
+// profile to everyone else, e.g. for the merchant payment screen
+func getWallet(c *gin.Context) {
+	wallet, err := fetchWallet(c.Param("id"))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	if canAccessWallet(c, wallet.ID) {
+		c.JSON(http.StatusOK, wallet)
+		return
+	}
+	c.JSON(http.StatusOK, WalletProfile{
+		ID:          wallet.ID,
+		Type:        wallet.Type,
+		Status:      wallet.Status,
+		DisplayName: wallet.DisplayName,
+		Category:    wallet.Category,
+		Location:    wallet.Location,
+		LogoRef:     wallet.LogoRef,
+		Department:  wallet.Department,
+	})
+}
+
+func updateWalletMetadata(c *gin.Context) {
+	id := c.Param("id")
+	if !canAccessWallet(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own wallet"})
+		return
+	}
+
+	var req UpdateWalletMetadataRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	// Only the fields sent are passed on; the chaincode merges them into the
+	// stored profile, so concurrent edits of other fields are kept
+	changesJSON, err := json.Marshal(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	result, err := blockchain.Contract.SubmitTransaction("UpdateWalletMetadata", id, string(changesJSON))
+	if err != nil {
+		respondChaincodeError(c, err)
+		return
+	}
+
+	var updated UserWallet
+	if err := json.Unmarshal(result, &updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse chaincode response"})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func fetchWallet(id string) (*UserWallet, error) {
+	result, err := blockchain.Contract.EvaluateTransaction("GetWallet", id)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallet UserWallet
+	if err := json.Unmarshal(result, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
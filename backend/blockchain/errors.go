@@ -0,0 +1,70 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"google.golang.org/grpc/status"
+)
+
+// Error codes returned by the chaincode as "CODE: message"
+const (
+	ErrCodeUnauthorized        = "UNAUTHORIZED"
+	ErrCodeForbidden           = "FORBIDDEN"
+	ErrCodeWalletFrozen        = "WALLET_FROZEN"
+	ErrCodeWalletClosed        = "WALLET_CLOSED"
+	ErrCodeLimitExceeded       = "LIMIT_EXCEEDED"
+	ErrCodeNotFound            = "NOT_FOUND"
+	ErrCodeRequestExpired      = "REQUEST_EXPIRED"
+	ErrCodeRequestNotOpen      = "REQUEST_NOT_OPEN"
+	ErrCodeRefundExceeded      = "REFUND_EXCEEDED"
+	ErrCodeHoldNotActive       = "HOLD_NOT_ACTIVE"
+	ErrCodeAllowanceExceeded   = "ALLOWANCE_EXCEEDED"
+	ErrCodeTransferNotAllowed  = "TRANSFER_NOT_ALLOWED"
+	ErrCodeIdempotencyConflict = "IDEMPOTENCY_CONFLICT"
+	ErrCodeProposalNotOpen     = "PROPOSAL_NOT_OPEN"
+)
+
+// ChaincodeError is a typed failure reported by the VapCoin chaincode
+type ChaincodeError struct {
+	Code    string
+	Message string
+}
+
+func (e *ChaincodeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Peers wrap the chaincode message, e.g. "chaincode response 500, FORBIDDEN: ..."
+var chaincodeErrorPattern = regexp.MustCompile(`(?:^|, )([A-Z][A-Z_]+): (.*)$`)
+
+// ParseError extracts the typed chaincode error from an error returned by
+// Contract.SubmitTransaction or Contract.EvaluateTransaction, or returns a
+// *ChaincodeError raised by the backend itself.
+func ParseError(err error) (*ChaincodeError, bool) {
+	if err == nil {
+		return nil, false
+	}
+	var ccErr *ChaincodeError
+	if errors.As(err, &ccErr) {
+		return ccErr, true
+	}
+
+	st := status.Convert(err)
+	var messages []string
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*gateway.ErrorDetail); ok {
+			messages = append(messages, d.GetMessage())
+		}
+	}
+	messages = append(messages, st.Message())
+
+	for _, message := range messages {
+		if match := chaincodeErrorPattern.FindStringSubmatch(message); match != nil {
+			return &ChaincodeError{Code: match[1], Message: match[2]}, true
+		}
+	}
+	return nil, false
+}
@@ -19,8 +19,8 @@ var Contract *client.Contract
 
 var (
 	mspID        = "Org1MSP"
-	certPath     = "../network/crypto-config/peerOrganizations/org1.example.com/users/User1@org1.example.com/msp/signcerts/User1@org1.example.com-cert.pem"
-	keyPath      = "../network/crypto-config/peerOrganizations/org1.example.com/users/User1@org1.example.com/msp/keystore/"
+	certPath     = "../network/crypto-config/peerOrganizations/org1.example.com/users/Admin@org1.example.com/msp/signcerts/Admin@org1.example.com-cert.pem"
+	keyPath      = "../network/crypto-config/peerOrganizations/org1.example.com/users/Admin@org1.example.com/msp/keystore/"
 	tlsCertPath  = "../network/crypto-config/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt"
 	peerEndpoint = "localhost:7051"
 	gatewayPeer  = "peer0.org1.example.com"
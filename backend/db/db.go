@@ -20,6 +20,29 @@ type User struct {
 	WalletID string `gorm:"uniqueIndex"`
 }
 
+// ScheduleRun is a schedule period the keeper saw paid on the ledger. The
+// ledger prevents double payment; this table is the backend's audit trail.
+type ScheduleRun struct {
+	gorm.Model
+	ScheduleID string `gorm:"uniqueIndex:idx_schedule_period"`
+	Period     int64  `gorm:"uniqueIndex:idx_schedule_period"` // Unix seconds
+	TxID       string
+	ExecutedAt time.Time
+	Recipients int
+	Total      int64 // minor units
+}
+
+// IdempotencyKey is a request completed for an Idempotency-Key header. Retries
+// with the same key are answered from here without calling the ledger, which
+// keeps its own record of the key as well.
+type IdempotencyKey struct {
+	gorm.Model
+	Scope       string `gorm:"uniqueIndex:idx_idempotency_scope_key"` // paying wallet, or "system" for mints
+	Key         string `gorm:"uniqueIndex:idx_idempotency_scope_key"`
+	RequestHash string // SHA-256 of the endpoint and request body
+	TxID        string
+}
+
 func Init() {
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
@@ -57,7 +80,7 @@ func Init() {
 	}
 
 	// Auto Migrate
-	err = DB.AutoMigrate(&User{})
+	err = DB.AutoMigrate(&User{}, &ScheduleRun{}, &IdempotencyKey{})
 	if err != nil {
 		log.Printf("Failed to migrate database: %v", err)
 	}
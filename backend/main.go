@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"vapcoin-backend/api"
 	"vapcoin-backend/blockchain"
@@ -26,6 +27,19 @@ func main() {
 		log.Fatalf("Failed to initialize blockchain connection: %v", err)
 	}
 
+	// Start the schedule keeper; an interval of 0 disables it
+	keeperInterval := time.Minute
+	if v := os.Getenv("SCHEDULE_KEEPER_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid SCHEDULE_KEEPER_INTERVAL: %v", err)
+		}
+		keeperInterval = d
+	}
+	if keeperInterval > 0 {
+		api.StartScheduleKeeper(keeperInterval)
+	}
+
 	// Setup Router
 	r := gin.Default()
 
@@ -33,8 +47,9 @@ func main() {
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Idempotency-Key")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
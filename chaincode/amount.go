@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Amounts are stored on the ledger as int64 minor units (paise).
+// 1 VAP = 100 minor units.
+const (
+	AmountDecimals = 2
+	AmountScale    = 100
+)
+
+// validateAmount rejects zero and negative amounts
+func validateAmount(amount int64) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be a positive number of minor units, got %d", amount)
+	}
+	return nil
+}
+
+// addAmount adds two amounts, failing instead of silently overflowing
+func addAmount(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, fmt.Errorf("amount overflow")
+	}
+	return a + b, nil
+}
+
+// legacyToMinorUnits converts a float64 amount from the pre-migration schema
+// to minor units, rounding to the nearest paisa.
+func legacyToMinorUnits(amount float64) (int64, error) {
+	scaled := math.Round(amount * AmountScale)
+	if math.IsNaN(scaled) || scaled >= math.MaxInt64 || scaled < math.MinInt64 {
+		return 0, fmt.Errorf("legacy amount %v is out of range", amount)
+	}
+	return int64(scaled), nil
+}
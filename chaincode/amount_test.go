@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAddAmountRejectsOverflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    int64
+		want    int64
+		wantErr bool
+	}{
+		{"sum", 1250, 100025, 101275, false},
+		{"negative", 1250, -250, 1000, false},
+		{"largest", math.MaxInt64 - 1, 1, math.MaxInt64, false},
+		{"overflow", math.MaxInt64, 1, 0, true},
+		{"underflow", math.MinInt64, -1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := addAmount(tt.a, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("addAmount(%d, %d) error = %v, wantErr %v", tt.a, tt.b, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("addAmount(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAmount(t *testing.T) {
+	for amount, wantErr := range map[int64]bool{1: false, 100 * AmountScale: false, 0: true, -1: true} {
+		if err := validateAmount(amount); (err != nil) != wantErr {
+			t.Errorf("validateAmount(%d) error = %v, wantErr %v", amount, err, wantErr)
+		}
+	}
+}
+
+func TestLegacyToMinorUnitsRoundsToNearestPaisa(t *testing.T) {
+	tests := []struct {
+		amount  float64
+		want    int64
+		wantErr bool
+	}{
+		{12.5, 1250, false},
+		{1000.25, 100025, false},
+		{0.1, 10, false},
+		{0.019, 2, false},
+		{0.014, 1, false},
+		{-3.33, -333, false},
+		{math.NaN(), 0, true},
+		{1e20, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := legacyToMinorUnits(tt.amount)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("legacyToMinorUnits(%v) error = %v, wantErr %v", tt.amount, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("legacyToMinorUnits(%v) = %d, want %d", tt.amount, got, tt.want)
+		}
+	}
+}
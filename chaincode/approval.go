@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// approvalObjectType keys allowances by owner and spender, e.g. approval/student1/club1
+const approvalObjectType = "approval"
+
+// Approval lets a spender move up to Amount out of the owner's wallet
+type Approval struct {
+	Owner     string `json:"owner"`
+	Spender   string `json:"spender"`
+	Amount    int64  `json:"amount"` // minor units still spendable
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// Approve sets how much spender may transfer out of owner's wallet, replacing
+// any previous allowance. An amount of zero revokes the allowance.
+func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, owner string, spender string, amount int64) (*Approval, error) {
+	if _, err := requireWalletAccess(ctx, owner); err != nil {
+		return nil, err
+	}
+	if amount < 0 {
+		return nil, fmt.Errorf("allowance cannot be negative")
+	}
+	if owner == spender {
+		return nil, fmt.Errorf("cannot approve a wallet to spend from itself")
+	}
+	if _, err := getWallet(ctx, owner); err != nil {
+		return nil, err
+	}
+	if _, err := getWallet(ctx, spender); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	approval := &Approval{Owner: owner, Spender: spender, Amount: amount, UpdatedAt: timestamp.Seconds}
+	if err := putApproval(ctx, approval); err != nil {
+		return nil, err
+	}
+
+	return approval, emitEvent(ctx, EventApproval, LedgerEvent{Approval: approval})
+}
+
+// Allowance returns how much spender may still transfer out of owner's wallet
+func (s *SmartContract) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (int64, error) {
+	approval, err := getApproval(ctx, owner, spender)
+	if err != nil {
+		return 0, err
+	}
+	return approval.Amount, nil
+}
+
+// TransferFrom lets spender pay amount from owner's wallet to to, drawing down
+// the allowance. The owner's status, holds and spending limits still apply.
+func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface, owner string, spender string, to string, amount int64) (*TransactionRecord, error) {
+	if _, err := requireWalletAccess(ctx, spender); err != nil {
+		return nil, err
+	}
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+
+	spenderWallet, err := getWallet(ctx, spender)
+	if err != nil {
+		return nil, err
+	}
+	if err := spenderWallet.RequireActive(); err != nil {
+		return nil, err
+	}
+
+	approval, err := getApproval(ctx, owner, spender)
+	if err != nil {
+		return nil, err
+	}
+	if amount > approval.Amount {
+		return nil, newContractError(ErrCodeAllowanceExceeded, "%s may spend %d from %s, requested %d", spender, approval.Amount, owner, amount)
+	}
+
+	record, fee, err := transfer(ctx, owner, to, amount, "transfer")
+	if err != nil {
+		return nil, err
+	}
+	record.Spender = spender
+	if err := putTransactionRecord(ctx, record); err != nil {
+		return nil, err
+	}
+
+	approval.Amount -= amount
+	approval.UpdatedAt = record.Timestamp
+	if err := putApproval(ctx, approval); err != nil {
+		return nil, err
+	}
+
+	return record, emitEvent(ctx, EventTransfer, LedgerEvent{Record: record, Fee: fee, Approval: approval})
+}
+
+// getApproval reads an allowance, returning a zero allowance when none is set
+func getApproval(ctx contractapi.TransactionContextInterface, owner string, spender string) (*Approval, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(approvalObjectType, []string{owner, spender})
+	if err != nil {
+		return nil, err
+	}
+	approvalJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if approvalJSON == nil {
+		return &Approval{Owner: owner, Spender: spender}, nil
+	}
+
+	var approval Approval
+	err = json.Unmarshal(approvalJSON, &approval)
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// putApproval stores an allowance, deleting it once it reaches zero
+func putApproval(ctx contractapi.TransactionContextInterface, approval *Approval) error {
+	key, err := ctx.GetStub().CreateCompositeKey(approvalObjectType, []string{approval.Owner, approval.Spender})
+	if err != nil {
+		return err
+	}
+	if approval.Amount == 0 {
+		return ctx.GetStub().DelState(key)
+	}
+
+	approvalJSON, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, approvalJSON)
+}
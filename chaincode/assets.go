@@ -0,0 +1,401 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	tokenObjectType = "token"
+	// assetBalanceObjectType keys non-VAP balances by wallet and asset, e.g. balance/student1/MEAL
+	assetBalanceObjectType = "balance"
+	// DefaultAsset is the built-in coin held in UserWallet.Balance. Records
+	// without an asset are VAP records.
+	DefaultAsset    = "VAP"
+	maxSymbolLength = 12
+)
+
+// Token describes an asset on the ledger. Amounts of every asset are stored
+// in the same minor units as VAP (1/100), so Decimals, at most AmountDecimals,
+// only limits the precision: a token with 0 decimals moves in multiples of 100.
+// Non-transferable tokens only move between the issuer and other wallets.
+type Token struct {
+	Symbol       string `json:"symbol"`
+	Name         string `json:"name"`
+	Decimals     int    `json:"decimals"`
+	Issuer       string `json:"issuer"` // wallet credited by Mint
+	Transferable bool   `json:"transferable"`
+	Supply       int64  `json:"supply"` // minor units in circulation
+	CreatedAt    int64  `json:"createdAt"`
+}
+
+// AssetBalance is the balance of one asset in one wallet
+type AssetBalance struct {
+	WalletID string `json:"walletId"`
+	Asset    string `json:"asset"`
+	Balance  int64  `json:"balance"` // minor units
+}
+
+// vapToken describes the built-in coin. It is not stored in the registry;
+// its balances live in UserWallet.Balance and its supply in SUPPLY.
+var vapToken = Token{Symbol: DefaultAsset, Name: "VapCoin", Decimals: AmountDecimals, Issuer: "admin", Transferable: true}
+
+// RegisterToken adds an asset to the token registry. Admin only.
+func (s *SmartContract) RegisterToken(ctx contractapi.TransactionContextInterface, symbol string, name string, decimals int, issuer string, transferable bool) (*Token, error) {
+	if _, err := requireAdmin(ctx, "RegisterToken"); err != nil {
+		return nil, err
+	}
+	if err := validateSymbol(symbol); err != nil {
+		return nil, err
+	}
+	if symbol == DefaultAsset {
+		return nil, fmt.Errorf("%s is the built-in asset", DefaultAsset)
+	}
+	if err := validateText("name", name, maxReferenceLength); err != nil {
+		return nil, err
+	}
+	if decimals < 0 || decimals > AmountDecimals {
+		return nil, fmt.Errorf("decimals must be between 0 and %d", AmountDecimals)
+	}
+	existing, err := getToken(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("token %s already exists", symbol)
+	}
+	if _, err := getWallet(ctx, issuer); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	token := &Token{
+		Symbol:       symbol,
+		Name:         name,
+		Decimals:     decimals,
+		Issuer:       issuer,
+		Transferable: transferable,
+		CreatedAt:    timestamp.Seconds,
+	}
+	return token, putToken(ctx, token)
+}
+
+// GetToken returns an asset from the registry. An empty symbol means VAP.
+func (s *SmartContract) GetToken(ctx contractapi.TransactionContextInterface, symbol string) (*Token, error) {
+	token, err := resolveAsset(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if token.Symbol == DefaultAsset {
+		supply, err := getSupply(ctx)
+		if err != nil {
+			return nil, err
+		}
+		token.Supply = supply.Total
+	}
+	return token, nil
+}
+
+// GetAllTokens returns VAP followed by every registered asset
+func (s *SmartContract) GetAllTokens(ctx contractapi.TransactionContextInterface) ([]*Token, error) {
+	vap, err := s.GetToken(ctx, DefaultAsset)
+	if err != nil {
+		return nil, err
+	}
+	tokens := []*Token{vap}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tokenObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var token Token
+		err = json.Unmarshal(response.Value, &token)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+
+	return tokens, nil
+}
+
+// GetBalances returns the VAP balance of a wallet and every other asset it holds
+func (s *SmartContract) GetBalances(ctx contractapi.TransactionContextInterface, walletID string) ([]*AssetBalance, error) {
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	others, err := getAssetBalances(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*AssetBalance{{WalletID: walletID, Asset: DefaultAsset, Balance: wallet.Balance}}, others...), nil
+}
+
+// mintAsset credits newly created units of a registered token to its issuer
+// and stores the mint record
+func mintAsset(ctx contractapi.TransactionContextInterface, token *Token, amount int64) (*TransactionRecord, error) {
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+	if err := token.validatePrecision(amount); err != nil {
+		return nil, err
+	}
+	issuer, err := getWallet(ctx, token.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := issuer.RequireActive(); err != nil {
+		return nil, err
+	}
+
+	token.Supply, err = addAmount(token.Supply, amount)
+	if err != nil {
+		return nil, err
+	}
+	if err := putToken(ctx, token); err != nil {
+		return nil, err
+	}
+	if err := creditAsset(ctx, issuer.ID, token.Symbol, amount); err != nil {
+		return nil, err
+	}
+
+	record, err := newTransactionRecord(ctx, "system", issuer.ID, amount, "mint")
+	if err != nil {
+		return nil, err
+	}
+	record.Asset = token.Symbol
+	return record, putTransactionRecord(ctx, record)
+}
+
+// transferAsset moves units of a registered token between two active wallets.
+// The transfer policy and the sender's spending limits on the token apply as
+// they do to VAP; holds, grants and fees exist for VAP only.
+func transferAsset(ctx contractapi.TransactionContextInterface, token *Token, fromID string, toID string, amount int64) (*TransactionRecord, error) {
+	if fromID == toID {
+		return nil, fmt.Errorf("cannot transfer to the same wallet")
+	}
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+	if err := token.validatePrecision(amount); err != nil {
+		return nil, err
+	}
+	if !token.Transferable && fromID != token.Issuer && toID != token.Issuer {
+		return nil, fmt.Errorf("%s can only be sent to or from its issuer %s", token.Symbol, token.Issuer)
+	}
+
+	fromWallet, err := getWallet(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fromWallet.RequireActive(); err != nil {
+		return nil, err
+	}
+	toWallet, err := getWallet(ctx, toID)
+	if err != nil {
+		return nil, err
+	}
+	if err := toWallet.RequireActive(); err != nil {
+		return nil, err
+	}
+
+	balance, err := getAssetBalance(ctx, fromID, token.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Balance < amount {
+		return nil, fmt.Errorf("insufficient funds")
+	}
+	if err := checkTransferPolicy(ctx, fromWallet, toWallet, token.Symbol, amount); err != nil {
+		return nil, err
+	}
+	if err := consumeSpendingAllowance(ctx, fromWallet, token.Symbol, amount); err != nil {
+		return nil, err
+	}
+	balance.Balance -= amount
+	if err := putAssetBalance(ctx, balance); err != nil {
+		return nil, err
+	}
+	if err := creditAsset(ctx, toID, token.Symbol, amount); err != nil {
+		return nil, err
+	}
+
+	record, err := newTransactionRecord(ctx, fromID, toID, amount, "transfer")
+	if err != nil {
+		return nil, err
+	}
+	record.Asset = token.Symbol
+	return record, nil
+}
+
+// resolveAsset returns the token for a symbol, treating an empty symbol as VAP
+func resolveAsset(ctx contractapi.TransactionContextInterface, symbol string) (*Token, error) {
+	if symbol == "" || symbol == DefaultAsset {
+		vap := vapToken
+		return &vap, nil
+	}
+	token, err := getToken(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, newContractError(ErrCodeNotFound, "token %s does not exist", symbol)
+	}
+	return token, nil
+}
+
+// validatePrecision rejects amounts finer than the token's decimals
+func (t *Token) validatePrecision(amount int64) error {
+	step := int64(math.Pow10(AmountDecimals - t.Decimals))
+	if amount%step != 0 {
+		return fmt.Errorf("%s amounts must be multiples of %d minor units", t.Symbol, step)
+	}
+	return nil
+}
+
+// validateSymbol accepts 2 to 12 upper-case letters and digits
+func validateSymbol(symbol string) error {
+	if len(symbol) < 2 || len(symbol) > maxSymbolLength {
+		return fmt.Errorf("symbol must be 2 to %d characters", maxSymbolLength)
+	}
+	for _, r := range symbol {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return fmt.Errorf("symbol must contain only A-Z and 0-9")
+		}
+	}
+	return nil
+}
+
+// assetOf returns the asset of a record, VAP for records without one
+func (r *TransactionRecord) assetOf() string {
+	if r.Asset == "" {
+		return DefaultAsset
+	}
+	return r.Asset
+}
+
+func getToken(ctx contractapi.TransactionContextInterface, symbol string) (*Token, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(tokenObjectType, []string{symbol})
+	if err != nil {
+		return nil, err
+	}
+	tokenJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if tokenJSON == nil {
+		return nil, nil
+	}
+
+	var token Token
+	err = json.Unmarshal(tokenJSON, &token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func putToken(ctx contractapi.TransactionContextInterface, token *Token) error {
+	key, err := ctx.GetStub().CreateCompositeKey(tokenObjectType, []string{token.Symbol})
+	if err != nil {
+		return err
+	}
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, tokenJSON)
+}
+
+// getAssetBalances returns the non-VAP balances held by a wallet
+func getAssetBalances(ctx contractapi.TransactionContextInterface, walletID string) ([]*AssetBalance, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(assetBalanceObjectType, []string{walletID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var balances []*AssetBalance
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var balance AssetBalance
+		err = json.Unmarshal(response.Value, &balance)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, &balance)
+	}
+
+	return balances, nil
+}
+
+// getAssetBalance reads a non-VAP balance, returning zero when none is stored
+func getAssetBalance(ctx contractapi.TransactionContextInterface, walletID string, symbol string) (*AssetBalance, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(assetBalanceObjectType, []string{walletID, symbol})
+	if err != nil {
+		return nil, err
+	}
+	balanceJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if balanceJSON == nil {
+		return &AssetBalance{WalletID: walletID, Asset: symbol}, nil
+	}
+
+	var balance AssetBalance
+	err = json.Unmarshal(balanceJSON, &balance)
+	if err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// putAssetBalance stores a non-VAP balance, deleting it once it reaches zero
+func putAssetBalance(ctx contractapi.TransactionContextInterface, balance *AssetBalance) error {
+	key, err := ctx.GetStub().CreateCompositeKey(assetBalanceObjectType, []string{balance.WalletID, balance.Asset})
+	if err != nil {
+		return err
+	}
+	if balance.Balance == 0 {
+		return ctx.GetStub().DelState(key)
+	}
+
+	balanceJSON, err := json.Marshal(balance)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, balanceJSON)
+}
+
+// creditAsset adds amount to a wallet's balance of a non-VAP asset
+func creditAsset(ctx contractapi.TransactionContextInterface, walletID string, symbol string, amount int64) error {
+	balance, err := getAssetBalance(ctx, walletID, symbol)
+	if err != nil {
+		return err
+	}
+	balance.Balance, err = addAmount(balance.Balance, amount)
+	if err != nil {
+		return err
+	}
+	return putAssetBalance(ctx, balance)
+}
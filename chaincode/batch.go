@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxBatchSize caps the lines of a single BatchTransfer so the write set
+// stays well within the endorsement size limits. Larger lists are split by the caller.
+const maxBatchSize = 200
+
+// BatchTransferItem is one line of a batch: a recipient and an amount in minor units
+type BatchTransferItem struct {
+	To     string `json:"to"`
+	Amount int64  `json:"amount"`
+}
+
+// BatchTransfer pays every item from fromID in a single transaction. Either
+// all lines succeed or none do. Each line gets its own record, with TxID
+// "<txId>_<line>" and BatchID set to the transaction ID. Lines paying a
+// merchant are charged the merchant fee like a Transfer, with a fee line item
+// "<txId>_<line>_fee". The batch counts as one transaction of its total
+// amount against the sender's spending limits. Repeating an idempotencyKey
+// with the same lines returns the original batch's line records instead of
+// paying again.
+func (s *SmartContract) BatchTransfer(ctx contractapi.TransactionContextInterface, fromID string, transfers []BatchTransferItem, idempotencyKey string) ([]*TransactionRecord, error) {
+	if _, err := requireWalletAccess(ctx, fromID); err != nil {
+		return nil, err
+	}
+	if len(transfers) == 0 {
+		return nil, fmt.Errorf("batch is empty")
+	}
+	if len(transfers) > maxBatchSize {
+		return nil, fmt.Errorf("batch has %d lines, the maximum is %d", len(transfers), maxBatchSize)
+	}
+	transfersJSON, err := json.Marshal(transfers)
+	if err != nil {
+		return nil, err
+	}
+	originalTxID, err := claimIdempotencyKey(ctx, fromID, idempotencyKey, "BatchTransfer", fromID, string(transfersJSON))
+	if err != nil {
+		return nil, err
+	}
+	if originalTxID != "" {
+		return getBatchRecords(ctx, originalTxID, len(transfers))
+	}
+
+	fromWallet, err := getWallet(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fromWallet.RequireActive(); err != nil {
+		return nil, err
+	}
+
+	// Recipients and fee treasuries are loaded once so repeated lines to the same wallet add up
+	recipients := make(map[string]*UserWallet)
+	loadRecipient := func(id string) (*UserWallet, error) {
+		if wallet, ok := recipients[id]; ok {
+			return wallet, nil
+		}
+		wallet, err := getWallet(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := wallet.RequireActive(); err != nil {
+			return nil, err
+		}
+		recipients[id] = wallet
+		return wallet, nil
+	}
+
+	var total int64
+	fees := make([]int64, len(transfers))
+	treasuries := make([]*UserWallet, len(transfers))
+	for i, item := range transfers {
+		if err := validateAmount(item.Amount); err != nil {
+			return nil, batchLineError(i, err)
+		}
+		if item.To == fromID {
+			return nil, fmt.Errorf("line %d: cannot transfer to the same wallet", i+1)
+		}
+		total, err = addAmount(total, item.Amount)
+		if err != nil {
+			return nil, batchLineError(i, err)
+		}
+
+		toWallet, err := loadRecipient(item.To)
+		if err != nil {
+			return nil, batchLineError(i, err)
+		}
+		if err := checkTransferPolicy(ctx, fromWallet, toWallet, DefaultAsset, item.Amount); err != nil {
+			return nil, batchLineError(i, err)
+		}
+		toWallet.Balance, err = addAmount(toWallet.Balance, item.Amount)
+		if err != nil {
+			return nil, batchLineError(i, err)
+		}
+
+		fee, treasuryID, err := merchantFee(ctx, toWallet, item.Amount)
+		if err != nil {
+			return nil, batchLineError(i, err)
+		}
+		if fee > 0 {
+			// The sender is credited after its debit below, so the fee cannot fund the batch
+			treasury := fromWallet
+			if treasuryID != fromID {
+				treasury, err = loadRecipient(treasuryID)
+				if err != nil {
+					return nil, batchLineError(i, err)
+				}
+			}
+			toWallet.debit(fee)
+			fees[i] = fee
+			treasuries[i] = treasury
+		}
+	}
+
+	if err := releaseExpiredHolds(ctx, fromWallet); err != nil {
+		return nil, err
+	}
+	if fromWallet.Available() < total {
+		return nil, fmt.Errorf("insufficient funds: batch total is %d, available is %d", total, fromWallet.Available())
+	}
+	if err := consumeSpendingAllowance(ctx, fromWallet, DefaultAsset, total); err != nil {
+		return nil, err
+	}
+
+	fromWallet.debit(total)
+	for i, treasury := range treasuries {
+		if treasury == nil {
+			continue
+		}
+		treasury.Balance, err = addAmount(treasury.Balance, fees[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := putWallet(ctx, fromWallet); err != nil {
+		return nil, err
+	}
+	for _, toWallet := range recipients {
+		if err := putWallet(ctx, toWallet); err != nil {
+			return nil, err
+		}
+	}
+
+	batchID := ctx.GetStub().GetTxID()
+	records := make([]*TransactionRecord, 0, len(transfers))
+	var feeRecords []*TransactionRecord
+	for i, item := range transfers {
+		record, err := newTransactionRecord(ctx, fromID, item.To, item.Amount, "transfer")
+		if err != nil {
+			return nil, err
+		}
+		record.TxID = fmt.Sprintf("%s_%d", batchID, i+1)
+		record.BatchID = batchID
+		if treasuries[i] != nil {
+			feeRecord := newFeeRecord(record, treasuries[i].ID, fees[i])
+			if err := putTransactionRecord(ctx, feeRecord); err != nil {
+				return nil, err
+			}
+			feeRecords = append(feeRecords, feeRecord)
+		}
+		if err := putTransactionRecord(ctx, record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, emitEvent(ctx, EventBatchTransfer, LedgerEvent{Records: append(records, feeRecords...)})
+}
+
+// getBatchRecords reads the line records of the batch committed as batchID
+func getBatchRecords(ctx contractapi.TransactionContextInterface, batchID string, lines int) ([]*TransactionRecord, error) {
+	records := make([]*TransactionRecord, lines)
+	for i := range records {
+		record, err := getTransactionRecord(ctx, fmt.Sprintf("%s_%d", batchID, i+1))
+		if err != nil {
+			return nil, err
+		}
+		records[i] = record
+	}
+	return records, nil
+}
+
+// batchLineError prefixes err with the 1-based line number, keeping the code
+// of typed errors at the front so clients can still read it
+func batchLineError(index int, err error) error {
+	var contractErr *ContractError
+	if errors.As(err, &contractErr) {
+		return newContractError(contractErr.Code, "line %d: %s", index+1, contractErr.Message)
+	}
+	return fmt.Errorf("line %d: %w", index+1, err)
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestBatchTransferIsAllOrNothing(t *testing.T) {
+	tests := []struct {
+		name      string
+		transfers []BatchTransferItem
+		wantErr   bool
+	}{
+		{"pays every line", []BatchTransferItem{{To: "student1", Amount: 500}, {To: "merchant1", Amount: 700}, {To: "student1", Amount: 300}}, false},
+		{"unknown recipient", []BatchTransferItem{{To: "student1", Amount: 500}, {To: "nobody", Amount: 700}}, true},
+		{"zero amount", []BatchTransferItem{{To: "student1", Amount: 500}, {To: "merchant1", Amount: 0}}, true},
+		{"pays the sender", []BatchTransferItem{{To: "admin", Amount: 500}}, true},
+		{"empty", nil, true},
+		{"too many lines", make([]BatchTransferItem, maxBatchSize+1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, s := newSeededLedger(t)
+			admin := l.wallet("admin").Balance
+
+			records, err := s.BatchTransfer(l.admin(), "admin", tt.transfers, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BatchTransfer error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got := l.wallet("admin").Balance; got != admin {
+					t.Errorf("admin balance = %d after a failed batch, want %d", got, admin)
+				}
+				return
+			}
+
+			if len(records) != len(tt.transfers) {
+				t.Fatalf("%d records, want %d", len(records), len(tt.transfers))
+			}
+			if records[1].TxID != l.stub.TxID+"_2" || records[1].BatchID != l.stub.TxID {
+				t.Errorf("line 2 = %+v, want TxID %s_2 in batch %s", records[1], l.stub.TxID, l.stub.TxID)
+			}
+			if got := l.wallet("student1").Balance; got != 100*AmountScale+800 {
+				t.Errorf("student1 balance = %d, want %d", got, 100*AmountScale+800)
+			}
+			if got := l.wallet("admin").Balance; got != admin-1500 {
+				t.Errorf("admin balance = %d, want %d", got, admin-1500)
+			}
+		})
+	}
+}
+
+func TestBatchTransferReplaysAnIdempotencyKey(t *testing.T) {
+	l, s := newSeededLedger(t)
+	chunk := []BatchTransferItem{{To: "student1", Amount: 500}, {To: "merchant1", Amount: 700}}
+	first, err := s.BatchTransfer(l.admin(), "admin", chunk, "upload-1-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := l.wallet("admin").Balance
+
+	replayed, err := s.BatchTransfer(l.admin(), "admin", chunk, "upload-1-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.wallet("admin").Balance; got != admin {
+		t.Errorf("admin balance = %d after the retry, want %d", got, admin)
+	}
+	if len(replayed) != len(first) || replayed[0].TxID != first[0].TxID || replayed[1].BatchID != first[0].BatchID {
+		t.Errorf("replayed records = %+v, want the original batch %s", replayed, first[0].BatchID)
+	}
+
+	if _, err := s.BatchTransfer(l.admin(), "admin", chunk[:1], "upload-1-1"); err == nil {
+		t.Error("reused the key for different lines")
+	}
+	if _, err := s.BatchTransfer(l.admin(), "admin", chunk, "upload-1-2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.wallet("admin").Balance; got != admin-1200 {
+		t.Errorf("admin balance = %d after a new key, want %d", got, admin-1200)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const maxReasonLength = 256
+
+// Burn destroys coins held by any wallet. Only admins may burn.
+func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, walletID string, amount int64, reason string) error {
+	if _, err := requireAdmin(ctx, "Burn"); err != nil {
+		return err
+	}
+
+	return burn(ctx, walletID, amount, reason)
+}
+
+// Redeem lets a merchant remove coins from circulation in exchange for a
+// cash settlement handled off-chain
+func (s *SmartContract) Redeem(ctx contractapi.TransactionContextInterface, walletID string, amount int64, reason string) error {
+	if _, err := requireWalletAccess(ctx, walletID); err != nil {
+		return err
+	}
+
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	if wallet.Type != "merchant" {
+		return newContractError(ErrCodeForbidden, "only merchant wallets can redeem, %s is a %s wallet", walletID, wallet.Type)
+	}
+	if err := wallet.RequireActive(); err != nil {
+		return err
+	}
+
+	return burn(ctx, walletID, amount, reason)
+}
+
+// burn debits the wallet, reduces the tracked supply and records a "burn" transaction
+func burn(ctx contractapi.TransactionContextInterface, walletID string, amount int64, reason string) error {
+	if err := validateAmount(amount); err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a reason is required to burn coins")
+	}
+	if len(reason) > maxReasonLength {
+		return fmt.Errorf("reason must be at most %d characters", maxReasonLength)
+	}
+
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	if err := releaseExpiredHolds(ctx, wallet); err != nil {
+		return err
+	}
+	if wallet.Available() < amount {
+		return fmt.Errorf("insufficient funds")
+	}
+
+	if err := adjustSupply(ctx, -amount); err != nil {
+		return err
+	}
+
+	wallet.debit(amount)
+	err = putWallet(ctx, wallet)
+	if err != nil {
+		return err
+	}
+
+	record, err := newTransactionRecord(ctx, walletID, "system", amount, "burn")
+	if err != nil {
+		return err
+	}
+	record.Reason = reason
+	if err := putTransactionRecord(ctx, record); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventBurn, LedgerEvent{Record: record})
+}
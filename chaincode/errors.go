@@ -0,0 +1,36 @@
+package main
+
+import "fmt"
+
+// Error codes carried in ContractError. The backend maps them to HTTP statuses.
+const (
+	ErrCodeUnauthorized        = "UNAUTHORIZED"         // caller identity could not be read
+	ErrCodeForbidden           = "FORBIDDEN"            // caller is not allowed to perform the action
+	ErrCodeWalletFrozen        = "WALLET_FROZEN"        // wallet is frozen and cannot move funds
+	ErrCodeWalletClosed        = "WALLET_CLOSED"        // wallet is closed and cannot move funds
+	ErrCodeLimitExceeded       = "LIMIT_EXCEEDED"       // transfer exceeds a spending limit
+	ErrCodeNotFound            = "NOT_FOUND"            // requested ledger object does not exist
+	ErrCodeRequestExpired      = "REQUEST_EXPIRED"      // payment request expired before it was paid
+	ErrCodeRequestNotOpen      = "REQUEST_NOT_OPEN"     // payment request was already paid or cancelled
+	ErrCodeRefundExceeded      = "REFUND_EXCEEDED"      // refunds would exceed the original payment
+	ErrCodeHoldNotActive       = "HOLD_NOT_ACTIVE"      // hold was already captured, released or expired
+	ErrCodeAllowanceExceeded   = "ALLOWANCE_EXCEEDED"   // TransferFrom exceeds the approved allowance
+	ErrCodeTransferNotAllowed  = "TRANSFER_NOT_ALLOWED" // transfer policy forbids payments between the wallet types
+	ErrCodeIdempotencyConflict = "IDEMPOTENCY_CONFLICT" // idempotency key was already used for a different request
+	ErrCodeProposalNotOpen     = "PROPOSAL_NOT_OPEN"    // mint proposal was already executed, rejected or has expired
+)
+
+// ContractError is a failure clients are expected to handle. It is rendered
+// as "CODE: message" so the code survives the trip through the peer gateway.
+type ContractError struct {
+	Code    string
+	Message string
+}
+
+func (e *ContractError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func newContractError(code string, format string, args ...interface{}) *ContractError {
+	return &ContractError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
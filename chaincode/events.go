@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Chaincode event names. Fabric keeps a single event per transaction, so
+// each state-changing function emits exactly one of these.
+// The payload schema is documented in docs/EVENTS.md.
+const (
+	EventTransfer                = "vapcoin.Transfer"
+	EventBatchTransfer           = "vapcoin.BatchTransfer"
+	EventMint                    = "vapcoin.Mint"
+	EventBurn                    = "vapcoin.Burn"
+	EventRefund                  = "vapcoin.Refund"
+	EventWalletCreated           = "vapcoin.WalletCreated"
+	EventWalletFrozen            = "vapcoin.WalletFrozen"
+	EventWalletUnfrozen          = "vapcoin.WalletUnfrozen"
+	EventWalletUpdated           = "vapcoin.WalletUpdated"
+	EventWalletClosed            = "vapcoin.WalletClosed"
+	EventPaymentRequestCreated   = "vapcoin.PaymentRequestCreated"
+	EventPaymentRequestCancelled = "vapcoin.PaymentRequestCancelled"
+	EventHoldPlaced              = "vapcoin.HoldPlaced"
+	EventHoldCaptured            = "vapcoin.HoldCaptured"
+	EventHoldReleased            = "vapcoin.HoldReleased"
+	EventApproval                = "vapcoin.Approval"
+	EventSchedulesExecuted       = "vapcoin.SchedulesExecuted"
+	EventGrant                   = "vapcoin.Grant"
+	EventGrantsExpired           = "vapcoin.GrantsExpired"
+	EventMintProposed            = "vapcoin.MintProposed"
+	EventMintApproved            = "vapcoin.MintApproved"
+	EventMintRejected            = "vapcoin.MintRejected"
+	EventMintApproversChanged    = "vapcoin.MintApproversChanged"
+)
+
+// eventSchemaVersion is bumped whenever LedgerEvent changes incompatibly
+const eventSchemaVersion = 1
+
+// LedgerEvent is the JSON payload of every VapCoin chaincode event
+type LedgerEvent struct {
+	SchemaVersion  int                  `json:"schemaVersion"`
+	Record         *TransactionRecord   `json:"record,omitempty"`
+	Records        []*TransactionRecord `json:"records,omitempty"`
+	Fee            *TransactionRecord   `json:"fee,omitempty"`
+	Wallet         *UserWallet          `json:"wallet,omitempty"`
+	PaymentRequest *PaymentRequest      `json:"paymentRequest,omitempty"`
+	Hold           *Hold                `json:"hold,omitempty"`
+	Approval       *Approval            `json:"approval,omitempty"`
+	Proposal       *MintProposal        `json:"proposal,omitempty"`
+	Governance     *MintGovernance      `json:"governance,omitempty"`
+}
+
+// emitEvent sets the chaincode event for the current transaction
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, event LedgerEvent) error {
+	event.SchemaVersion = eventSchemaVersion
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().SetEvent(name, payload)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	feePolicyObjectType = "feepolicy"
+	// defaultFeeCategory holds the policy for merchants without a category-specific one
+	defaultFeeCategory = "default"
+	// maxFeeBasisPoints is 100%
+	maxFeeBasisPoints = 10000
+)
+
+// FeePolicy is the fee taken from merchant receipts of one merchant category.
+// The fee is Fixed plus BasisPoints/10000 of the payment, clamped to
+// [Min, Max] and never more than the payment. A Max of zero means no maximum.
+type FeePolicy struct {
+	Category    string `json:"category"`
+	BasisPoints int64  `json:"basisPoints"` // 1 basis point = 0.01%
+	Fixed       int64  `json:"fixed"`       // minor units
+	Min         int64  `json:"min"`         // minor units
+	Max         int64  `json:"max"`         // minor units
+	TreasuryID  string `json:"treasuryId"`  // wallet credited with the fee
+	UpdatedAt   int64  `json:"updatedAt"`
+}
+
+// SetFeePolicy sets the fee for merchants of a category, or for all merchants
+// without their own policy when category is "default". Admin only.
+func (s *SmartContract) SetFeePolicy(ctx contractapi.TransactionContextInterface, category string, basisPoints int64, fixed int64, minFee int64, maxFee int64, treasuryID string) (*FeePolicy, error) {
+	if _, err := requireAdmin(ctx, "SetFeePolicy"); err != nil {
+		return nil, err
+	}
+	if category != defaultFeeCategory && !transferCategories[category] {
+		return nil, fmt.Errorf("unknown category %q", category)
+	}
+	if basisPoints < 0 || basisPoints > maxFeeBasisPoints {
+		return nil, fmt.Errorf("basis points must be between 0 and %d", maxFeeBasisPoints)
+	}
+	if fixed < 0 || minFee < 0 || maxFee < 0 {
+		return nil, fmt.Errorf("fee amounts cannot be negative")
+	}
+	if maxFee != 0 && maxFee < minFee {
+		return nil, fmt.Errorf("maximum fee is below the minimum")
+	}
+	treasury, err := getWallet(ctx, treasuryID)
+	if err != nil {
+		return nil, err
+	}
+	if treasury.Type == "merchant" {
+		return nil, fmt.Errorf("the treasury cannot be a merchant wallet")
+	}
+	if err := treasury.RequireActive(); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	policy := &FeePolicy{
+		Category:    category,
+		BasisPoints: basisPoints,
+		Fixed:       fixed,
+		Min:         minFee,
+		Max:         maxFee,
+		TreasuryID:  treasuryID,
+		UpdatedAt:   timestamp.Seconds,
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(feePolicyObjectType, []string{category})
+	if err != nil {
+		return nil, err
+	}
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	return policy, ctx.GetStub().PutState(key, policyJSON)
+}
+
+// ClearFeePolicy removes the fee policy of a category. Admin only.
+func (s *SmartContract) ClearFeePolicy(ctx contractapi.TransactionContextInterface, category string) error {
+	if _, err := requireAdmin(ctx, "ClearFeePolicy"); err != nil {
+		return err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(feePolicyObjectType, []string{category})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().DelState(key)
+}
+
+// GetFeePolicy returns the fee policy stored for a category
+func (s *SmartContract) GetFeePolicy(ctx contractapi.TransactionContextInterface, category string) (*FeePolicy, error) {
+	policy, err := getFeePolicy(ctx, category)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, newContractError(ErrCodeNotFound, "no fee policy for category %s", category)
+	}
+	return policy, nil
+}
+
+// SetMerchantCategory assigns the category that selects a merchant's fee
+// policy. It is the only way to change a wallet's category. Admin only.
+func (s *SmartContract) SetMerchantCategory(ctx contractapi.TransactionContextInterface, walletID string, category string) error {
+	if _, err := requireAdmin(ctx, "SetMerchantCategory"); err != nil {
+		return err
+	}
+	if category != "" && !transferCategories[category] {
+		return fmt.Errorf("unknown category %q", category)
+	}
+
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	if wallet.Type != "merchant" {
+		return fmt.Errorf("only merchant wallets have a category, %s is a %s wallet", walletID, wallet.Type)
+	}
+
+	wallet.Category = category
+	if err := putWallet(ctx, wallet); err != nil {
+		return err
+	}
+	return emitEvent(ctx, EventWalletUpdated, LedgerEvent{Wallet: wallet})
+}
+
+// collectMerchantFee moves the fee on a payment from the merchant to the
+// treasury and stores the fee line item as "<txId>_fee". payer is the loaded
+// paying wallet, used when it is also the treasury. It returns nil when no fee
+// applies. Payments fail while the treasury is frozen or closed, so fees never
+// land in a wallet that cannot move them.
+func collectMerchantFee(ctx contractapi.TransactionContextInterface, payer *UserWallet, merchant *UserWallet, payment *TransactionRecord) (*TransactionRecord, error) {
+	fee, treasuryID, err := merchantFee(ctx, merchant, payment.Amount)
+	if err != nil || fee == 0 {
+		return nil, err
+	}
+
+	// The payer's wallet was already updated in this transaction and must not be re-read
+	treasury := payer
+	if treasuryID != payer.ID {
+		treasury, err = getWallet(ctx, treasuryID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := treasury.RequireActive(); err != nil {
+		return nil, err
+	}
+
+	merchant.debit(fee)
+	treasury.Balance, err = addAmount(treasury.Balance, fee)
+	if err != nil {
+		return nil, err
+	}
+	if err := putWallet(ctx, merchant); err != nil {
+		return nil, err
+	}
+	if err := putWallet(ctx, treasury); err != nil {
+		return nil, err
+	}
+
+	feeRecord := newFeeRecord(payment, treasury.ID, fee)
+	return feeRecord, putTransactionRecord(ctx, feeRecord)
+}
+
+// merchantFee returns the fee due on a payment of amount to merchant and the
+// treasury it goes to. The fee is zero for other wallets and without a policy.
+func merchantFee(ctx contractapi.TransactionContextInterface, merchant *UserWallet, amount int64) (int64, string, error) {
+	if merchant.Type != "merchant" {
+		return 0, "", nil
+	}
+	policy, err := getMerchantFeePolicy(ctx, merchant)
+	if err != nil || policy == nil {
+		return 0, "", err
+	}
+	return policy.feeFor(amount), policy.TreasuryID, nil
+}
+
+// newFeeRecord sets the fee on payment and builds its "<txId>_fee" line item
+func newFeeRecord(payment *TransactionRecord, treasuryID string, fee int64) *TransactionRecord {
+	payment.Fee = fee
+	return &TransactionRecord{
+		TxID:        payment.TxID + "_fee",
+		From:        payment.To,
+		To:          treasuryID,
+		Amount:      fee,
+		Timestamp:   payment.Timestamp,
+		Type:        "fee",
+		PaymentTxID: payment.TxID,
+	}
+}
+
+// feeFor computes the fee on amount
+func (p *FeePolicy) feeFor(amount int64) int64 {
+	// Split the multiplication so large amounts cannot overflow; the
+	// percentage is at most the amount, so only the fixed part can push past it
+	fee := amount/maxFeeBasisPoints*p.BasisPoints + amount%maxFeeBasisPoints*p.BasisPoints/maxFeeBasisPoints
+	if p.Fixed > amount-fee {
+		fee = amount
+	} else {
+		fee += p.Fixed
+	}
+	if fee < p.Min {
+		fee = p.Min
+	}
+	if p.Max > 0 && fee > p.Max {
+		fee = p.Max
+	}
+	return min(fee, amount)
+}
+
+// getMerchantFeePolicy returns the merchant's category policy, else the default, else nil
+func getMerchantFeePolicy(ctx contractapi.TransactionContextInterface, merchant *UserWallet) (*FeePolicy, error) {
+	if merchant.Category != "" {
+		policy, err := getFeePolicy(ctx, merchant.Category)
+		if err != nil || policy != nil {
+			return policy, err
+		}
+	}
+	return getFeePolicy(ctx, defaultFeeCategory)
+}
+
+func getFeePolicy(ctx contractapi.TransactionContextInterface, category string) (*FeePolicy, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(feePolicyObjectType, []string{category})
+	if err != nil {
+		return nil, err
+	}
+	policyJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if policyJSON == nil {
+		return nil, nil
+	}
+
+	var policy FeePolicy
+	err = json.Unmarshal(policyJSON, &policy)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFeeForRoundsDownAndClamps(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy FeePolicy
+		amount int64
+		want   int64
+	}{
+		{"no fee", FeePolicy{}, 4000, 0},
+		{"percentage", FeePolicy{BasisPoints: 250}, 4000, 100},
+		{"rounds down", FeePolicy{BasisPoints: 250}, 39, 0},
+		{"one basis point below a unit", FeePolicy{BasisPoints: 1}, 9999, 0},
+		{"one basis point", FeePolicy{BasisPoints: 1}, 10000, 1},
+		{"fixed part", FeePolicy{BasisPoints: 250, Fixed: 10}, 4000, 110},
+		{"minimum", FeePolicy{BasisPoints: 100, Min: 50}, 1000, 50},
+		{"maximum", FeePolicy{BasisPoints: 1000, Max: 200}, 10000, 200},
+		{"fixed above the payment", FeePolicy{BasisPoints: 250, Fixed: 500}, 300, 300},
+		{"minimum above the payment", FeePolicy{Min: 50}, 20, 20},
+		{"whole payment", FeePolicy{BasisPoints: maxFeeBasisPoints}, 4000, 4000},
+		{"largest amount", FeePolicy{BasisPoints: 5000}, math.MaxInt64, math.MaxInt64 / 2},
+		{"largest amount with a fixed part", FeePolicy{BasisPoints: maxFeeBasisPoints, Fixed: 1}, math.MaxInt64, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.feeFor(tt.amount); got != tt.want {
+				t.Errorf("feeFor(%d) = %d, want %d", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerchantFeeIsCollectedToTheTreasury(t *testing.T) {
+	l, s := newSeededLedger(t)
+	if _, err := s.SetFeePolicy(l.admin(), defaultFeeCategory, 250, 10, 0, 0, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetFeePolicy(l.admin(), defaultFeeCategory, 250, 10, 0, 0, "merchant1"); err == nil {
+		t.Error("a merchant became the fee treasury")
+	}
+	admin := l.wallet("admin").Balance
+
+	student := testIdentity{role: "student", wallet: "student1"}
+	txID, err := s.Transfer(l.tx(student), "student1", "merchant1", 4000, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, err := s.GetTransaction(l.admin(), txID+"_fee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee.Amount != 110 || fee.From != "merchant1" || fee.To != "admin" || fee.PaymentTxID != txID {
+		t.Errorf("fee record = %+v, want 110 from merchant1 to admin for %s", fee, txID)
+	}
+	if got := l.wallet("merchant1").Balance; got != 3890 {
+		t.Errorf("merchant1 balance = %d, want 3890", got)
+	}
+	if got := l.wallet("admin").Balance; got != admin+110 {
+		t.Errorf("admin balance = %d, want %d", got, admin+110)
+	}
+
+	// Payments between students are not merchant receipts
+	if err := s.CreateWallet(l.admin(), "student2", "student"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Transfer(l.tx(student), "student1", "student2", 1000, "", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.wallet("student2").Balance; got != 1000 {
+		t.Errorf("student2 balance = %d, want 1000 without a fee", got)
+	}
+}
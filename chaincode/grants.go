@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// maxSweepRecords bounds the expiry records written by one SweepExpired call;
+	// the remaining lots are swept by the next call
+	maxSweepRecords = 200
+	// grantExpiryIndex lists the unspent grant lots by expiry, so a sweep only
+	// reads the lots that are due. putWallet keeps it in step with the wallets.
+	grantExpiryIndex = "expiry~wallet~grant"
+)
+
+// GrantLot is an amount given to a wallet that must be spent before ExpiresAt.
+// Lots are kept on the wallet in the order they were granted and are spent
+// oldest first. The unspent part of an expired lot cannot be spent and is
+// returned to Treasury by SweepExpired.
+type GrantLot struct {
+	TxID      string `json:"txId"`   // transaction that granted the lot
+	Amount    int64  `json:"amount"` // minor units not yet spent
+	ExpiresAt int64  `json:"expiresAt"`
+	Treasury  string `json:"treasury"` // wallet the expired amount returns to
+}
+
+// SweepResult is the outcome of SweepExpired
+type SweepResult struct {
+	Records []*TransactionRecord `json:"records"`
+	Total   int64                `json:"total"`
+	Pending bool                 `json:"pending"` // more expired lots remain than one call sweeps
+}
+
+// Grant pays amount from a treasury wallet to a wallet as a lot that expires
+// at expiresAt, a Unix timestamp in seconds. Admin only.
+func (s *SmartContract) Grant(ctx contractapi.TransactionContextInterface, treasuryID string, walletID string, amount int64, expiresAt int64) (*TransactionRecord, error) {
+	if _, err := requireAdmin(ctx, "Grant"); err != nil {
+		return nil, err
+	}
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+	if treasuryID == walletID {
+		return nil, fmt.Errorf("cannot grant to the treasury itself")
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt <= timestamp.Seconds {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+
+	treasury, err := getWallet(ctx, treasuryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := treasury.RequireActive(); err != nil {
+		return nil, err
+	}
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := wallet.RequireActive(); err != nil {
+		return nil, err
+	}
+
+	if err := releaseExpiredHolds(ctx, treasury); err != nil {
+		return nil, err
+	}
+	if treasury.Available() < amount {
+		return nil, fmt.Errorf("insufficient funds")
+	}
+
+	treasury.debit(amount)
+	wallet.Balance, err = addAmount(wallet.Balance, amount)
+	if err != nil {
+		return nil, err
+	}
+	txID := ctx.GetStub().GetTxID()
+	wallet.Grants = append(wallet.Grants, &GrantLot{TxID: txID, Amount: amount, ExpiresAt: expiresAt, Treasury: treasuryID})
+
+	if err := putWallet(ctx, treasury); err != nil {
+		return nil, err
+	}
+	if err := putWallet(ctx, wallet); err != nil {
+		return nil, err
+	}
+
+	record, err := newTransactionRecord(ctx, treasuryID, walletID, amount, "grant")
+	if err != nil {
+		return nil, err
+	}
+	record.ExpiresAt = expiresAt
+	if err := putTransactionRecord(ctx, record); err != nil {
+		return nil, err
+	}
+
+	return record, emitEvent(ctx, EventGrant, LedgerEvent{Record: record})
+}
+
+// SweepExpired returns the unspent part of every expired grant lot to its
+// treasury, writing one "expiry" record per lot. Funds reserved by holds are
+// left in place and swept once the hold is released, and lots whose treasury
+// is frozen wait until it is unfrozen. Admin only.
+func (s *SmartContract) SweepExpired(ctx contractapi.TransactionContextInterface) (*SweepResult, error) {
+	if _, err := requireAdmin(ctx, "SweepExpired"); err != nil {
+		return nil, err
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	walletIDs, pending, err := getExpiredGrantWallets(ctx, timestamp.Seconds)
+	if err != nil {
+		return nil, err
+	}
+
+	// Treasuries may also be swept wallets, so every wallet is loaded once
+	wallets := newWalletCache(ctx)
+	result := &SweepResult{Records: []*TransactionRecord{}, Pending: pending}
+	txID := ctx.GetStub().GetTxID()
+	for _, walletID := range walletIDs {
+		wallet, err := wallets.get(walletID)
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := wallet.Grants[:0]
+		for _, lot := range wallet.Grants {
+			if lot.ExpiresAt > wallet.asOf || len(result.Records) == maxSweepRecords {
+				result.Pending = result.Pending || lot.ExpiresAt <= wallet.asOf
+				remaining = append(remaining, lot)
+				continue
+			}
+
+			swept := min(lot.Amount, max(wallet.Balance-wallet.Held, 0))
+			if swept == 0 {
+				remaining = append(remaining, lot)
+				continue
+			}
+			treasury, err := wallets.get(lot.Treasury)
+			if err != nil {
+				return nil, err
+			}
+			// A frozen treasury can't be credited; the lot waits for a later sweep
+			if treasury.RequireActive() != nil {
+				remaining = append(remaining, lot)
+				continue
+			}
+
+			wallet.Balance -= swept
+			treasury.Balance, err = addAmount(treasury.Balance, swept)
+			if err != nil {
+				return nil, err
+			}
+			lot.Amount -= swept
+			if lot.Amount > 0 {
+				remaining = append(remaining, lot)
+			}
+
+			record, err := newTransactionRecord(ctx, wallet.ID, treasury.ID, swept, "expiry")
+			if err != nil {
+				return nil, err
+			}
+			record.TxID = fmt.Sprintf("%s_%d", txID, len(result.Records)+1)
+			record.GrantTxID = lot.TxID
+			if err := putTransactionRecord(ctx, record); err != nil {
+				return nil, err
+			}
+			result.Records = append(result.Records, record)
+			result.Total += swept
+		}
+		wallet.Grants = remaining
+	}
+
+	if err := wallets.putAll(); err != nil {
+		return nil, err
+	}
+	if len(result.Records) == 0 {
+		return result, nil
+	}
+	return result, emitEvent(ctx, EventGrantsExpired, LedgerEvent{Records: result.Records})
+}
+
+// getExpiredGrantWallets returns the wallets holding the first
+// maxSweepRecords lots that expired at or before now, in expiry order, and
+// whether more expired lots remain
+func getExpiredGrantWallets(ctx contractapi.TransactionContextInterface, now int64) ([]string, bool, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(grantExpiryIndex, []string{})
+	if err != nil {
+		return nil, false, err
+	}
+	defer resultsIterator.Close()
+
+	var walletIDs []string
+	seen := make(map[string]bool)
+	lots := 0
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, false, err
+		}
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(compositeKeyParts) < 3 {
+			continue
+		}
+		expiresAt, err := strconv.ParseInt(compositeKeyParts[0], 10, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid grant expiry key %s: %v", response.Key, err)
+		}
+		// Keys are in expiry order, so the first lot still running ends the scan
+		if expiresAt > now {
+			break
+		}
+		if lots == maxSweepRecords {
+			return walletIDs, true, nil
+		}
+		lots++
+		if !seen[compositeKeyParts[1]] {
+			seen[compositeKeyParts[1]] = true
+			walletIDs = append(walletIDs, compositeKeyParts[1])
+		}
+	}
+
+	return walletIDs, false, nil
+}
+
+// grantExpiryKey is the grantExpiryIndex key of a wallet's lot
+func grantExpiryKey(ctx contractapi.TransactionContextInterface, walletID string, lot *GrantLot) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(grantExpiryIndex, []string{fmt.Sprintf("%020d", lot.ExpiresAt), walletID, lot.TxID})
+}
+
+// debit takes amount out of the wallet, spending unexpired grant lots oldest
+// first. The caller checks that the funds are available.
+func (w *UserWallet) debit(amount int64) {
+	w.Balance -= amount
+	for _, lot := range w.Grants {
+		if amount == 0 {
+			break
+		}
+		if lot.ExpiresAt > w.asOf {
+			spent := min(lot.Amount, amount)
+			lot.Amount -= spent
+			amount -= spent
+		}
+	}
+
+	// Captures may spend held funds of expired lots, and lots never exceed the balance
+	excess := w.grantTotal() - max(w.Balance, 0)
+	for _, lot := range w.Grants {
+		if excess <= 0 {
+			break
+		}
+		trimmed := min(lot.Amount, excess)
+		lot.Amount -= trimmed
+		excess -= trimmed
+	}
+
+	remaining := w.Grants[:0]
+	for _, lot := range w.Grants {
+		if lot.Amount > 0 {
+			remaining = append(remaining, lot)
+		}
+	}
+	w.Grants = remaining
+}
+
+// ExpiringGrants returns the unspent amount of lots that have not expired yet
+func (w *UserWallet) ExpiringGrants() int64 {
+	var total int64
+	for _, lot := range w.Grants {
+		if lot.ExpiresAt > w.asOf {
+			total += lot.Amount
+		}
+	}
+	return total
+}
+
+// ExpiredGrants returns the unspent amount of expired lots awaiting SweepExpired
+func (w *UserWallet) ExpiredGrants() int64 {
+	return w.grantTotal() - w.ExpiringGrants()
+}
+
+func (w *UserWallet) grantTotal() int64 {
+	var total int64
+	for _, lot := range w.Grants {
+		total += lot.Amount
+	}
+	return total
+}
@@ -0,0 +1,106 @@
+package main
+
+import "testing"
+
+func TestDebitSpendsGrantLotsOldestFirst(t *testing.T) {
+	tests := []struct {
+		name        string
+		balance     int64
+		lots        []GrantLot
+		amount      int64
+		wantBalance int64
+		wantLots    []int64
+	}{
+		{"within the oldest lot", 1000, []GrantLot{{TxID: "a", Amount: 300, ExpiresAt: 300}, {TxID: "b", Amount: 200, ExpiresAt: 200}}, 100, 900, []int64{200, 200}},
+		{"across lots", 1000, []GrantLot{{TxID: "a", Amount: 300, ExpiresAt: 300}, {TxID: "b", Amount: 200, ExpiresAt: 200}}, 400, 600, []int64{100}},
+		{"beyond the lots", 1000, []GrantLot{{TxID: "a", Amount: 300, ExpiresAt: 300}, {TxID: "b", Amount: 200, ExpiresAt: 200}}, 800, 200, nil},
+		{"skips expired lots", 1000, []GrantLot{{TxID: "a", Amount: 300, ExpiresAt: 100}, {TxID: "b", Amount: 200, ExpiresAt: 300}}, 250, 750, []int64{300}},
+		{"trims lots to the balance", 500, []GrantLot{{TxID: "a", Amount: 300, ExpiresAt: 100}}, 400, 100, []int64{100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallet := &UserWallet{Balance: tt.balance, asOf: 100}
+			for i := range tt.lots {
+				lot := tt.lots[i]
+				wallet.Grants = append(wallet.Grants, &lot)
+			}
+
+			wallet.debit(tt.amount)
+			if wallet.Balance != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", wallet.Balance, tt.wantBalance)
+			}
+			var lots []int64
+			for _, lot := range wallet.Grants {
+				lots = append(lots, lot.Amount)
+			}
+			if len(lots) != len(tt.wantLots) {
+				t.Fatalf("lots = %v, want %v", lots, tt.wantLots)
+			}
+			for i := range lots {
+				if lots[i] != tt.wantLots[i] {
+					t.Errorf("lots = %v, want %v", lots, tt.wantLots)
+				}
+			}
+		})
+	}
+}
+
+func TestSweepExpiredReturnsUnspentLots(t *testing.T) {
+	l, s := newSeededLedger(t)
+	start := l.now
+	for _, lot := range []struct{ amount, expiresAt int64 }{{1000, start + 100}, {500, start + 1000}} {
+		if _, err := s.Grant(l.admin(), "admin", "student1", lot.amount, lot.expiresAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Transfer(l.tx(testIdentity{role: "student", wallet: "student1"}), "student1", "merchant1", 300, "", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name        string
+		offset      int64
+		wantSwept   int64
+		wantBalance int64
+		wantIndexed int
+	}{
+		{"nothing expired", 99, 0, 11200, 2},
+		{"first lot expired", 100, 700, 10500, 1},
+		{"already swept", 500, 0, 10500, 1},
+		{"second lot expired", 1000, 500, 10000, 0},
+	}
+	for _, step := range steps {
+		l.now = start + step.offset
+		result, err := s.SweepExpired(l.admin())
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if result.Total != step.wantSwept || result.Pending {
+			t.Errorf("%s: swept %d pending %v, want %d", step.name, result.Total, result.Pending, step.wantSwept)
+		}
+		if got := l.wallet("student1").Balance; got != step.wantBalance {
+			t.Errorf("%s: student1 balance = %d, want %d", step.name, got, step.wantBalance)
+		}
+		if got := countKeys(t, l, grantExpiryIndex); got != step.wantIndexed {
+			t.Errorf("%s: %d lots in the expiry index, want %d", step.name, got, step.wantIndexed)
+		}
+	}
+}
+
+// countKeys counts the composite keys of an object type
+func countKeys(t *testing.T, l *testLedger, objectType string) int {
+	t.Helper()
+	iterator, err := l.stub.GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iterator.Close()
+	count := 0
+	for iterator.HasNext() {
+		if _, err := iterator.Next(); err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	return count
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// WalletHistoryEntry is one committed change to a wallet key
+type WalletHistoryEntry struct {
+	TxID         string      `json:"txId"`
+	Timestamp    int64       `json:"timestamp"` // Unix seconds
+	IsDelete     bool        `json:"isDelete"`
+	Wallet       *UserWallet `json:"wallet,omitempty"` // state after the change, absent for deletes
+	BalanceDelta int64       `json:"balanceDelta"`     // change in balance from the previous entry, in minor units
+}
+
+// PaginatedWalletHistory describes a page of wallet history, newest first
+type PaginatedWalletHistory struct {
+	Entries      []*WalletHistoryEntry `json:"entries"`
+	Bookmark     string                `json:"bookmark"`
+	RecordsCount int                   `json:"recordsCount"`
+}
+
+// GetHistory returns the changes to a wallet key, newest first. The
+// bookmark is the txId of the last entry of the previous page.
+func (s *SmartContract) GetHistory(ctx contractapi.TransactionContextInterface, id string, pageSize int32, bookmark string) (*PaginatedWalletHistory, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be positive")
+	}
+	if id == "" || isReservedKey(id) {
+		return nil, fmt.Errorf("invalid wallet id %q", id)
+	}
+
+	migratedAt, err := getMigrationTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	page := &PaginatedWalletHistory{Entries: []*WalletHistoryEntry{}}
+	skipping := bookmark != ""
+	var previous *WalletHistoryEntry
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &WalletHistoryEntry{
+			TxID:      response.TxId,
+			Timestamp: response.Timestamp.GetSeconds(),
+			IsDelete:  response.IsDelete,
+		}
+		if !response.IsDelete {
+			legacy := response.Timestamp.AsTime().Before(migratedAt)
+			entry.Wallet, err = decodeWalletSnapshot(response.Value, legacy)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode wallet %s at %s: %v", id, response.TxId, err)
+			}
+		}
+
+		// Entries arrive newest first, so each one completes the delta of the one before it
+		if previous != nil {
+			previous.BalanceDelta = snapshotBalance(previous) - snapshotBalance(entry)
+			previous = nil
+		}
+
+		if skipping {
+			skipping = entry.TxID != bookmark
+			continue
+		}
+		if len(page.Entries) == int(pageSize) {
+			page.Bookmark = page.Entries[len(page.Entries)-1].TxID
+			break
+		}
+		page.Entries = append(page.Entries, entry)
+		previous = entry
+	}
+
+	// The oldest entry created the wallet, so its whole balance is the delta
+	if previous != nil {
+		previous.BalanceDelta = snapshotBalance(previous)
+	}
+
+	page.RecordsCount = len(page.Entries)
+	return page, nil
+}
+
+// decodeWalletSnapshot parses a historic wallet value. Values written before
+// MigrateToMinorUnits use the float64 layout and are converted.
+func decodeWalletSnapshot(value []byte, legacy bool) (*UserWallet, error) {
+	if !legacy {
+		var wallet UserWallet
+		err := json.Unmarshal(value, &wallet)
+		return &wallet, err
+	}
+
+	var old legacyUserWallet
+	if err := json.Unmarshal(value, &old); err != nil {
+		return nil, err
+	}
+	balance, err := legacyToMinorUnits(old.Balance)
+	if err != nil {
+		return nil, err
+	}
+	return &UserWallet{ID: old.ID, Balance: balance, Type: old.Type}, nil
+}
+
+func snapshotBalance(entry *WalletHistoryEntry) int64 {
+	if entry.Wallet == nil {
+		return 0
+	}
+	return entry.Wallet.Balance
+}
+
+// getMigrationTime returns when the ledger switched to minor units: the
+// first write of the current schema version marker. Ledgers without the
+// marker have not been migrated, so every historic value is legacy.
+func getMigrationTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(schemaVersionKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resultsIterator.Close()
+
+	migratedAt := time.Unix(math.MaxInt32, 0)
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return time.Time{}, err
+		}
+		// History is newest first, so the last match is the first write
+		if !response.IsDelete && string(response.Value) == currentSchemaVersion {
+			migratedAt = response.Timestamp.AsTime()
+		}
+	}
+
+	return migratedAt, nil
+}
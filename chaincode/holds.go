@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Hold states. Active holds past ExpiresAt are released automatically the
+// next time funds leave the wallet, or explicitly through ExpireHolds.
+const (
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusReleased = "released"
+	HoldStatusExpired  = "expired"
+)
+
+const (
+	holdObjectType = "hold"
+	// walletHoldIndex lists the active holds of each wallet
+	walletHoldIndex = "wallet~hold"
+)
+
+// Hold reserves funds in a wallet for a merchant until the final amount is known
+type Hold struct {
+	ID             string `json:"id"`
+	WalletID       string `json:"walletId"`
+	MerchantID     string `json:"merchantId"`
+	Amount         int64  `json:"amount"` // minor units reserved
+	CapturedAmount int64  `json:"capturedAmount"`
+	Reference      string `json:"reference"`
+	Status         string `json:"status"`
+	CreatedAt      int64  `json:"createdAt"`
+	ExpiresAt      int64  `json:"expiresAt"`
+	ClosedAt       int64  `json:"closedAt,omitempty"`
+	CaptureTxID    string `json:"captureTxId,omitempty"`
+}
+
+// BalanceDetails splits a wallet balance into held and spendable funds
+type BalanceDetails struct {
+	WalletID  string      `json:"walletId"`
+	Total     int64       `json:"total"`
+	Held      int64       `json:"held"`
+	Available int64       `json:"available"`
+	Expiring  int64       `json:"expiring"` // granted funds that expire unless spent, see grants.go
+	Expired   int64       `json:"expired"`  // expired granted funds awaiting SweepExpired
+	Grants    []*GrantLot `json:"grants,omitempty"`
+}
+
+// Available returns the funds that are not reserved by holds or locked in expired grants
+func (w *UserWallet) Available() int64 {
+	return max(w.Balance-w.Held-w.ExpiredGrants(), 0)
+}
+
+// PlaceHold reserves amount in walletID for merchantID. The reservation
+// counts against the wallet's spending limits until the hold closes; then only
+// a captured amount still counts. expiry is a Unix timestamp in seconds.
+func (s *SmartContract) PlaceHold(ctx contractapi.TransactionContextInterface, walletID string, merchantID string, amount int64, reference string, expiry int64) (*Hold, error) {
+	if _, err := requireWalletAccess(ctx, walletID); err != nil {
+		return nil, err
+	}
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+	if reference == "" {
+		return nil, fmt.Errorf("reference is required")
+	}
+	if err := validateText("reference", reference, maxReferenceLength); err != nil {
+		return nil, err
+	}
+	if walletID == merchantID {
+		return nil, fmt.Errorf("cannot place a hold for the same wallet")
+	}
+
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := wallet.RequireActive(); err != nil {
+		return nil, err
+	}
+	merchant, err := getWallet(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.Type != "merchant" {
+		return nil, fmt.Errorf("holds can only be placed for merchant wallets, %s is a %s wallet", merchantID, merchant.Type)
+	}
+	if err := merchant.RequireActive(); err != nil {
+		return nil, err
+	}
+	if err := checkTransferPolicy(ctx, wallet, merchant, DefaultAsset, amount); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if expiry <= timestamp.Seconds {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+
+	if err := releaseExpiredHolds(ctx, wallet); err != nil {
+		return nil, err
+	}
+	if wallet.Available() < amount {
+		return nil, fmt.Errorf("insufficient funds")
+	}
+	if err := addSpendEntry(ctx, wallet, DefaultAsset, amount, ctx.GetStub().GetTxID()); err != nil {
+		return nil, err
+	}
+
+	wallet.Held += amount
+	if err := putWallet(ctx, wallet); err != nil {
+		return nil, err
+	}
+
+	hold := &Hold{
+		ID:         ctx.GetStub().GetTxID(),
+		WalletID:   walletID,
+		MerchantID: merchantID,
+		Amount:     amount,
+		Reference:  reference,
+		Status:     HoldStatusActive,
+		CreatedAt:  timestamp.Seconds,
+		ExpiresAt:  expiry,
+	}
+	if err := putHold(ctx, hold); err != nil {
+		return nil, err
+	}
+
+	return hold, emitEvent(ctx, EventHoldPlaced, LedgerEvent{Hold: hold})
+}
+
+// CaptureHold settles a hold by paying amount to the merchant. The amount may
+// be less than the hold; the remainder is released. Only the merchant may capture.
+func (s *SmartContract) CaptureHold(ctx contractapi.TransactionContextInterface, holdID string, amount int64) (*TransactionRecord, error) {
+	hold, err := getActiveHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := requireWalletAccess(ctx, hold.MerchantID); err != nil {
+		return nil, err
+	}
+	if amount > hold.Amount {
+		return nil, fmt.Errorf("capture of %d exceeds the held amount of %d", amount, hold.Amount)
+	}
+
+	wallet, err := getWallet(ctx, hold.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	merchant, err := getWallet(ctx, hold.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Release the reservation, then pay from the now available funds
+	wallet.Held -= hold.Amount
+	record, fee, err := moveFunds(ctx, wallet, merchant, amount, "capture")
+	if err != nil {
+		return nil, err
+	}
+	record.HoldID = hold.ID
+	record.Reference = hold.Reference
+	if err := putTransactionRecord(ctx, record); err != nil {
+		return nil, err
+	}
+
+	hold.Status = HoldStatusCaptured
+	hold.CapturedAmount = amount
+	hold.ClosedAt = record.Timestamp
+	hold.CaptureTxID = record.TxID
+	if err := closeHold(ctx, hold); err != nil {
+		return nil, err
+	}
+	if err := settleHoldSpending(ctx, hold.WalletID, map[string]int64{hold.ID: amount}); err != nil {
+		return nil, err
+	}
+
+	return record, emitEvent(ctx, EventHoldCaptured, LedgerEvent{Record: record, Fee: fee, Hold: hold})
+}
+
+// ReleaseHold cancels a hold without payment. Only the merchant may release.
+func (s *SmartContract) ReleaseHold(ctx contractapi.TransactionContextInterface, holdID string) (*Hold, error) {
+	hold, err := getActiveHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := requireWalletAccess(ctx, hold.MerchantID); err != nil {
+		return nil, err
+	}
+
+	wallet, err := getWallet(ctx, hold.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	wallet.Held -= hold.Amount
+	if err := putWallet(ctx, wallet); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	hold.Status = HoldStatusReleased
+	hold.ClosedAt = timestamp.Seconds
+	if err := closeHold(ctx, hold); err != nil {
+		return nil, err
+	}
+	if err := settleHoldSpending(ctx, hold.WalletID, map[string]int64{hold.ID: 0}); err != nil {
+		return nil, err
+	}
+
+	return hold, emitEvent(ctx, EventHoldReleased, LedgerEvent{Hold: hold})
+}
+
+// ExpireHolds releases every expired hold on a wallet
+func (s *SmartContract) ExpireHolds(ctx contractapi.TransactionContextInterface, walletID string) error {
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return err
+	}
+
+	return releaseExpiredHolds(ctx, wallet)
+}
+
+// GetHold returns a hold by ID
+func (s *SmartContract) GetHold(ctx contractapi.TransactionContextInterface, holdID string) (*Hold, error) {
+	return getHold(ctx, holdID)
+}
+
+// GetBalanceDetails returns the total, held, available and granted balance of
+// a wallet. Expired holds are already excluded from the held amount.
+func (s *SmartContract) GetBalanceDetails(ctx contractapi.TransactionContextInterface, walletID string) (*BalanceDetails, error) {
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	expired, err := getExpiredHolds(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	for _, hold := range expired {
+		wallet.Held -= hold.Amount
+	}
+
+	return &BalanceDetails{
+		WalletID:  walletID,
+		Total:     wallet.Balance,
+		Held:      wallet.Held,
+		Available: wallet.Available(),
+		Expiring:  wallet.ExpiringGrants(),
+		Expired:   wallet.ExpiredGrants(),
+		Grants:    wallet.Grants,
+	}, nil
+}
+
+// releaseExpiredHolds marks the wallet's expired holds as expired and frees
+// their funds. It writes the holds but leaves storing the wallet to the caller.
+func releaseExpiredHolds(ctx contractapi.TransactionContextInterface, wallet *UserWallet) error {
+	if wallet.Held == 0 {
+		return nil
+	}
+
+	expired, err := getExpiredHolds(ctx, wallet.ID)
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	unpaid := make(map[string]int64, len(expired))
+	for _, hold := range expired {
+		wallet.Held -= hold.Amount
+		hold.Status = HoldStatusExpired
+		hold.ClosedAt = hold.ExpiresAt
+		if err := closeHold(ctx, hold); err != nil {
+			return err
+		}
+		unpaid[hold.ID] = 0
+	}
+	if err := settleHoldSpending(ctx, wallet.ID, unpaid); err != nil {
+		return err
+	}
+
+	return putWallet(ctx, wallet)
+}
+
+// getExpiredHolds returns the active holds on a wallet that are past their expiry
+func getExpiredHolds(ctx contractapi.TransactionContextInterface, walletID string) ([]*Hold, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(walletHoldIndex, []string{walletID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var expired []*Hold
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+
+		hold, err := getHold(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		if timestamp.Seconds > hold.ExpiresAt {
+			expired = append(expired, hold)
+		}
+	}
+
+	return expired, nil
+}
+
+// getActiveHold reads a hold that can still be captured or released
+func getActiveHold(ctx contractapi.TransactionContextInterface, holdID string) (*Hold, error) {
+	hold, err := getHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != HoldStatusActive {
+		return nil, newContractError(ErrCodeHoldNotActive, "hold %s is %s", holdID, hold.Status)
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if timestamp.Seconds > hold.ExpiresAt {
+		return nil, newContractError(ErrCodeHoldNotActive, "hold %s expired at %d", holdID, hold.ExpiresAt)
+	}
+
+	return hold, nil
+}
+
+func getHold(ctx contractapi.TransactionContextInterface, holdID string) (*Hold, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(holdObjectType, []string{holdID})
+	if err != nil {
+		return nil, err
+	}
+	holdJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if holdJSON == nil {
+		return nil, newContractError(ErrCodeNotFound, "hold %s does not exist", holdID)
+	}
+
+	var hold Hold
+	err = json.Unmarshal(holdJSON, &hold)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// putHold stores an active hold and adds it to the wallet's hold index
+func putHold(ctx contractapi.TransactionContextInterface, hold *Hold) error {
+	if err := writeHold(ctx, hold); err != nil {
+		return err
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(walletHoldIndex, []string{hold.WalletID, hold.ID})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// closeHold stores a finished hold and removes it from the wallet's hold index
+func closeHold(ctx contractapi.TransactionContextInterface, hold *Hold) error {
+	if err := writeHold(ctx, hold); err != nil {
+		return err
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(walletHoldIndex, []string{hold.WalletID, hold.ID})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().DelState(indexKey)
+}
+
+func writeHold(ctx contractapi.TransactionContextInterface, hold *Hold) error {
+	key, err := ctx.GetStub().CreateCompositeKey(holdObjectType, []string{hold.ID})
+	if err != nil {
+		return err
+	}
+	holdJSON, err := json.Marshal(hold)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, holdJSON)
+}
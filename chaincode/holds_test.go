@@ -0,0 +1,113 @@
+package main
+
+import "testing"
+
+func TestHoldSettlement(t *testing.T) {
+	student := testIdentity{role: "student", wallet: "student1"}
+	merchant := testIdentity{role: "merchant", wallet: "merchant1"}
+	tests := []struct {
+		name         string
+		settle       func(l *testLedger, s *SmartContract, hold *Hold) error
+		wantStatus   string
+		wantBalance  int64
+		wantMerchant int64
+		wantSpent    int64
+	}{
+		{
+			name: "full capture",
+			settle: func(l *testLedger, s *SmartContract, hold *Hold) error {
+				_, err := s.CaptureHold(l.tx(merchant), hold.ID, hold.Amount)
+				return err
+			},
+			wantStatus: HoldStatusCaptured, wantBalance: 7000, wantMerchant: 3000, wantSpent: 3000,
+		},
+		{
+			name: "partial capture",
+			settle: func(l *testLedger, s *SmartContract, hold *Hold) error {
+				_, err := s.CaptureHold(l.tx(merchant), hold.ID, 1200)
+				return err
+			},
+			wantStatus: HoldStatusCaptured, wantBalance: 8800, wantMerchant: 1200, wantSpent: 1200,
+		},
+		{
+			name: "release",
+			settle: func(l *testLedger, s *SmartContract, hold *Hold) error {
+				_, err := s.ReleaseHold(l.tx(merchant), hold.ID)
+				return err
+			},
+			wantStatus: HoldStatusReleased, wantBalance: 10000, wantSpent: 0,
+		},
+		{
+			name: "expiry",
+			settle: func(l *testLedger, s *SmartContract, hold *Hold) error {
+				l.now = hold.ExpiresAt + 1
+				return s.ExpireHolds(l.tx(student), hold.WalletID)
+			},
+			wantStatus: HoldStatusExpired, wantBalance: 10000, wantSpent: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, s := newSeededLedger(t)
+			hold, err := s.PlaceHold(l.tx(student), "student1", "merchant1", 3000, "order-1", l.now+3600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Transfer(l.tx(student), "student1", "merchant1", 7001, "", "", "", ""); err == nil {
+				t.Fatal("transfer of held funds succeeded")
+			}
+
+			if err := tt.settle(l, s, hold); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.GetHold(l.tx(student), hold.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			if wallet := l.wallet("student1"); wallet.Balance != tt.wantBalance || wallet.Held != 0 {
+				t.Errorf("student1 balance = %d held = %d, want %d and 0", wallet.Balance, wallet.Held, tt.wantBalance)
+			}
+			if balance := l.wallet("merchant1").Balance; balance != tt.wantMerchant {
+				t.Errorf("merchant1 balance = %d, want %d", balance, tt.wantMerchant)
+			}
+			allowance, err := s.GetRemainingAllowance(l.tx(student), "student1", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowance.SpentToday != tt.wantSpent {
+				t.Errorf("spent today = %d, want %d", allowance.SpentToday, tt.wantSpent)
+			}
+		})
+	}
+}
+
+func TestHoldCannotBeSettledTwice(t *testing.T) {
+	l, s := newSeededLedger(t)
+	merchant := testIdentity{role: "merchant", wallet: "merchant1"}
+	hold, err := s.PlaceHold(l.tx(testIdentity{role: "student", wallet: "student1"}), "student1", "merchant1", 3000, "order-1", l.now+3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.CaptureHold(l.tx(testIdentity{role: "student", wallet: "student1"}), hold.ID, 3000); err == nil {
+		t.Error("the payer captured its own hold")
+	}
+	if _, err := s.CaptureHold(l.tx(merchant), hold.ID, 3001); err == nil {
+		t.Error("captured more than the held amount")
+	}
+	if _, err := s.CaptureHold(l.tx(merchant), hold.ID, 3000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReleaseHold(l.tx(merchant), hold.ID); err == nil {
+		t.Error("released a captured hold")
+	}
+	if _, err := s.CaptureHold(l.tx(merchant), hold.ID, 3000); err == nil {
+		t.Error("captured a hold twice")
+	}
+	if balance := l.wallet("merchant1").Balance; balance != 3000 {
+		t.Errorf("merchant1 balance = %d, want 3000", balance)
+	}
+}
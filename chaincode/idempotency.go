@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// idempotencyObjectType keys claimed idempotency keys by scope and key, e.g. idempotency/student1/<key>
+	idempotencyObjectType   = "idempotency"
+	maxIdempotencyKeyLength = 64
+)
+
+// IdempotencyRecord remembers the transaction that first used a client-supplied
+// idempotency key. Keys are scoped to the paying wallet, or "system" for
+// mints, and never expire.
+type IdempotencyRecord struct {
+	Scope       string `json:"scope"`
+	Key         string `json:"key"`
+	Function    string `json:"function"`
+	RequestHash string `json:"requestHash"` // SHA-256 of the function arguments
+	TxID        string `json:"txId"`
+	CreatedAt   int64  `json:"createdAt"`
+}
+
+// GetIdempotencyRecord returns the transaction an idempotency key was used
+// for. Callers may only look up keys of wallets they act for.
+func (s *SmartContract) GetIdempotencyRecord(ctx contractapi.TransactionContextInterface, scope string, key string) (*IdempotencyRecord, error) {
+	if _, err := requireWalletAccess(ctx, scope); err != nil {
+		return nil, err
+	}
+	record, err := getIdempotencyRecord(ctx, scope, key)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, newContractError(ErrCodeNotFound, "idempotency key %s has not been used", key)
+	}
+	return record, nil
+}
+
+// claimIdempotencyKey records key for the current transaction. When the key
+// was already used for the same function and arguments it returns the
+// original transaction ID, and the caller returns it without executing again.
+// Reusing a key for a different request fails with IDEMPOTENCY_CONFLICT. An
+// empty key claims nothing.
+//
+// Two submissions racing with the same key both read it as unused, but only
+// the first to commit is valid; the other fails the MVCC check.
+func claimIdempotencyKey(ctx contractapi.TransactionContextInterface, scope string, key string, function string, args ...string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+	if err := validateText("idempotency key", key, maxIdempotencyKeyLength); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(append([]string{function}, args...), "\x00")))
+	requestHash := hex.EncodeToString(sum[:])
+
+	existing, err := getIdempotencyRecord(ctx, scope, key)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		if existing.Function != function || existing.RequestHash != requestHash {
+			return "", newContractError(ErrCodeIdempotencyConflict, "idempotency key %s was already used for a different request", key)
+		}
+		return existing.TxID, nil
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", err
+	}
+	record := &IdempotencyRecord{
+		Scope:       scope,
+		Key:         key,
+		Function:    function,
+		RequestHash: requestHash,
+		TxID:        ctx.GetStub().GetTxID(),
+		CreatedAt:   timestamp.Seconds,
+	}
+
+	compositeKey, err := ctx.GetStub().CreateCompositeKey(idempotencyObjectType, []string{scope, key})
+	if err != nil {
+		return "", err
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	return "", ctx.GetStub().PutState(compositeKey, recordJSON)
+}
+
+func getIdempotencyRecord(ctx contractapi.TransactionContextInterface, scope string, key string) (*IdempotencyRecord, error) {
+	compositeKey, err := ctx.GetStub().CreateCompositeKey(idempotencyObjectType, []string{scope, key})
+	if err != nil {
+		return nil, err
+	}
+	recordJSON, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if recordJSON == nil {
+		return nil, nil
+	}
+
+	var record IdempotencyRecord
+	err = json.Unmarshal(recordJSON, &record)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransferReplaysAnIdempotencyKey(t *testing.T) {
+	l, s := newSeededLedger(t)
+	student := testIdentity{role: "student", wallet: "student1"}
+	first, err := s.Transfer(l.tx(student), "student1", "merchant1", 1000, "", "", "", "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name       string
+		from       string
+		amount     int64
+		key        string
+		wantCode   string
+		wantReplay bool
+	}{
+		{"retry", "student1", 1000, "order-1", "", true},
+		{"different amount", "student1", 2000, "order-1", ErrCodeIdempotencyConflict, false},
+		{"other sender's key space", "admin", 1000, "order-1", "", false},
+		{"new key", "student1", 1000, "order-2", "", false},
+		{"no key", "student1", 1000, "", "", false},
+	}
+	for _, step := range steps {
+		ctx := l.tx(student)
+		if step.from == "admin" {
+			ctx = l.admin()
+		}
+		txID, err := s.Transfer(ctx, step.from, "merchant1", step.amount, "", "", "", step.key)
+		if got := errorCode(err); got != step.wantCode || (err != nil && step.wantCode == "") {
+			t.Fatalf("%s: error = %v, want %q", step.name, err, step.wantCode)
+		}
+		if err != nil {
+			continue
+		}
+		if replayed := txID == first; replayed != step.wantReplay {
+			t.Errorf("%s: returned %s, replay of %s = %v, want %v", step.name, txID, first, replayed, step.wantReplay)
+		}
+	}
+
+	if got := l.wallet("merchant1").Balance; got != 4000 {
+		t.Errorf("merchant1 balance = %d, want 4000 from four payments", got)
+	}
+	record, err := s.GetIdempotencyRecord(l.admin(), "student1", "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TxID != first || record.Function != "Transfer" {
+		t.Errorf("idempotency record = %+v, want Transfer %s", record, first)
+	}
+}
+
+func TestMintReplaysAnIdempotencyKey(t *testing.T) {
+	l, s := newSeededLedger(t)
+	registerApprovers(t, l, s, 2, "approver1", "approver2", "approver3")
+	proposal, err := s.ProposeMint(l.admin(), 5000, "", "term top-up", l.now+3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, approver := range []string{"approver1", "approver2"} {
+		if _, err := s.ApproveMint(l.tx(approverIdentity(approver)), proposal.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := l.wallet("admin").Balance
+
+	first, err := s.Mint(l.admin(), proposal.ID, "mint-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := s.Mint(l.admin(), proposal.ID, "mint-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != first {
+		t.Errorf("retry returned %s, want %s", replayed, first)
+	}
+	if _, err := s.Mint(l.admin(), proposal.ID, "mint-2"); errorCode(err) != ErrCodeProposalNotOpen {
+		t.Errorf("second execution error = %v, want %s", err, ErrCodeProposalNotOpen)
+	}
+	if got := l.wallet("admin").Balance; got != admin+5000 {
+		t.Errorf("admin balance = %d, want %d", got, admin+5000)
+	}
+}
+
+// errorCode returns the code of a ContractError, or "" for other errors and nil
+func errorCode(err error) string {
+	var contractErr *ContractError
+	if errors.As(err, &contractErr) {
+		return contractErr.Code
+	}
+	return ""
+}
+
+// approverIdentity is an admin credential of a mint approver
+func approverIdentity(name string) testIdentity {
+	return testIdentity{role: roleAdmin, wallet: name}
+}
+
+// registerApprovers registers the first mint approvers by name
+func registerApprovers(t *testing.T, l *testLedger, s *SmartContract, threshold int, names ...string) {
+	t.Helper()
+	var approvers []string
+	for _, name := range names {
+		id, _ := approverIdentity(name).GetID()
+		approvers = append(approvers, id)
+	}
+	if _, err := s.SetMintApprovers(l.admin(), approvers, threshold); err != nil {
+		t.Fatal(err)
+	}
+}
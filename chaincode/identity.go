@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Certificate attributes issued by the Fabric CA for VapCoin identities
+const (
+	roleAttribute   = "vapcoin.role"   // "admin", "student" or "merchant"
+	walletAttribute = "vapcoin.wallet" // wallet the identity may spend from
+)
+
+// adminMSPID is the only organization whose identities may act as admins
+const adminMSPID = "Org1MSP"
+
+const roleAdmin = "admin"
+
+// caller describes the identity that submitted the current transaction
+type caller struct {
+	MSPID    string
+	ID       string
+	Role     string
+	WalletID string
+}
+
+func (c *caller) IsAdmin() bool {
+	return c.Role == roleAdmin
+}
+
+// CanActFor reports whether the caller may spend from the given wallet.
+// Admin identities (such as the backend gateway) act on behalf of any wallet.
+func (c *caller) CanActFor(walletID string) bool {
+	return c.IsAdmin() || (c.WalletID != "" && c.WalletID == walletID)
+}
+
+// getCaller reads the MSP ID and VapCoin attributes of the submitting identity.
+// Identities without a vapcoin.role attribute (e.g. cryptogen certificates) are
+// treated as admins only when they carry the "admin" node OU of adminMSPID.
+func getCaller(ctx contractapi.TransactionContextInterface) (*caller, error) {
+	identity := ctx.GetClientIdentity()
+
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return nil, newContractError(ErrCodeUnauthorized, "failed to read caller MSP ID: %v", err)
+	}
+	id, err := identity.GetID()
+	if err != nil {
+		return nil, newContractError(ErrCodeUnauthorized, "failed to read caller ID: %v", err)
+	}
+
+	role, found, err := identity.GetAttributeValue(roleAttribute)
+	if err != nil {
+		return nil, newContractError(ErrCodeUnauthorized, "failed to read %s attribute: %v", roleAttribute, err)
+	}
+	if !found {
+		cert, err := identity.GetX509Certificate()
+		if err != nil {
+			return nil, newContractError(ErrCodeUnauthorized, "failed to read caller certificate: %v", err)
+		}
+		if cert != nil {
+			for _, ou := range cert.Subject.OrganizationalUnit {
+				if ou == roleAdmin {
+					role = roleAdmin
+				}
+			}
+		}
+	}
+
+	// Attributes from other organizations' CAs cannot grant admin rights
+	if role == roleAdmin && mspID != adminMSPID {
+		role = ""
+	}
+
+	walletID, _, err := identity.GetAttributeValue(walletAttribute)
+	if err != nil {
+		return nil, newContractError(ErrCodeUnauthorized, "failed to read %s attribute: %v", walletAttribute, err)
+	}
+
+	return &caller{MSPID: mspID, ID: id, Role: role, WalletID: walletID}, nil
+}
+
+// requireAdmin rejects callers that are not VapCoin admins
+func requireAdmin(ctx contractapi.TransactionContextInterface, action string) (*caller, error) {
+	c, err := getCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !c.IsAdmin() {
+		return nil, newContractError(ErrCodeForbidden, "%s requires an admin identity (caller %s from %s)", action, c.ID, c.MSPID)
+	}
+	return c, nil
+}
+
+// requireWalletAccess rejects callers that may not spend from walletID
+func requireWalletAccess(ctx contractapi.TransactionContextInterface, walletID string) (*caller, error) {
+	c, err := getCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !c.CanActFor(walletID) {
+		return nil, newContractError(ErrCodeForbidden, "caller %s from %s may not act for wallet %s", c.ID, c.MSPID, walletID)
+	}
+	return c, nil
+}
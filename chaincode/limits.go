@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key object types for spending limits and usage.
+// Limits are keyed by scope and ID, e.g. limit/role/student or limit/wallet/student1.
+// Limits on a registered asset add its symbol, e.g. limit/role/student/MEAL,
+// since amounts of different assets cannot be added up.
+const (
+	limitObjectType       = "limit"
+	spendWindowObjectType = "spendwindow"
+
+	limitScopeRole   = "role"
+	limitScopeWallet = "wallet"
+)
+
+// spendWindowSeconds is the length of the rolling day used for limits
+const spendWindowSeconds = 24 * 60 * 60
+
+// SpendingLimit caps outgoing transfers from a wallet. Zero means unlimited.
+type SpendingLimit struct {
+	MaxPerTransaction     int64  `json:"maxPerTransaction"` // minor units
+	MaxPerDay             int64  `json:"maxPerDay"`         // minor units, rolling 24 hours
+	MaxTransactionsPerDay int64  `json:"maxTransactionsPerDay"`
+	Source                string `json:"source,omitempty"` // "wallet", "role" or "" when no limit is configured
+}
+
+// spendEntry is one outgoing transfer inside the rolling window. Entries of
+// holds carry the hold ID, so they can be settled when the hold closes.
+type spendEntry struct {
+	Timestamp int64  `json:"timestamp"`
+	Amount    int64  `json:"amount"`
+	HoldID    string `json:"holdId,omitempty"`
+}
+
+// spendWindow holds the outgoing transfers of a wallet in the last rolling day
+type spendWindow struct {
+	Entries []spendEntry `json:"entries"`
+}
+
+// SpendingAllowance is what a wallet may still spend of an asset in the current
+// rolling day. Remaining values are -1 when the corresponding limit is unlimited.
+type SpendingAllowance struct {
+	WalletID              string        `json:"walletId"`
+	Asset                 string        `json:"asset"`
+	Limit                 SpendingLimit `json:"limit"`
+	SpentToday            int64         `json:"spentToday"`
+	TransactionsToday     int64         `json:"transactionsToday"`
+	RemainingAmount       int64         `json:"remainingAmount"`
+	RemainingTransactions int64         `json:"remainingTransactions"`
+	WindowStart           int64         `json:"windowStart"`
+}
+
+// SetRoleSpendingLimit sets the default limit for every wallet of a role on
+// an asset; an empty asset means VAP. Admin only.
+func (s *SmartContract) SetRoleSpendingLimit(ctx contractapi.TransactionContextInterface, role string, maxPerTransaction int64, maxPerDay int64, maxTransactionsPerDay int64, asset string) error {
+	if _, err := requireAdmin(ctx, "SetRoleSpendingLimit"); err != nil {
+		return err
+	}
+	if role == "" {
+		return fmt.Errorf("role is required")
+	}
+	token, err := resolveAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	limit := SpendingLimit{MaxPerTransaction: maxPerTransaction, MaxPerDay: maxPerDay, MaxTransactionsPerDay: maxTransactionsPerDay}
+	return putSpendingLimit(ctx, limitScopeRole, role, token.Symbol, &limit)
+}
+
+// SetWalletSpendingLimit overrides the role default on an asset for a single
+// wallet; an empty asset means VAP. Admin only.
+func (s *SmartContract) SetWalletSpendingLimit(ctx contractapi.TransactionContextInterface, walletID string, maxPerTransaction int64, maxPerDay int64, maxTransactionsPerDay int64, asset string) error {
+	if _, err := requireAdmin(ctx, "SetWalletSpendingLimit"); err != nil {
+		return err
+	}
+	if _, err := getWallet(ctx, walletID); err != nil {
+		return err
+	}
+	token, err := resolveAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	limit := SpendingLimit{MaxPerTransaction: maxPerTransaction, MaxPerDay: maxPerDay, MaxTransactionsPerDay: maxTransactionsPerDay}
+	return putSpendingLimit(ctx, limitScopeWallet, walletID, token.Symbol, &limit)
+}
+
+// ClearWalletSpendingLimit removes a wallet override on an asset so the role
+// default applies again. Admin only.
+func (s *SmartContract) ClearWalletSpendingLimit(ctx contractapi.TransactionContextInterface, walletID string, asset string) error {
+	if _, err := requireAdmin(ctx, "ClearWalletSpendingLimit"); err != nil {
+		return err
+	}
+	token, err := resolveAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(limitObjectType, assetKeyAttributes(token.Symbol, limitScopeWallet, walletID))
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().DelState(key)
+}
+
+// GetSpendingLimit returns the limit that applies to a wallet on an asset
+func (s *SmartContract) GetSpendingLimit(ctx contractapi.TransactionContextInterface, walletID string, asset string) (*SpendingLimit, error) {
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := resolveAsset(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return getEffectiveSpendingLimit(ctx, wallet, token.Symbol)
+}
+
+// GetRemainingAllowance returns how much of an asset a wallet may still spend
+// in the current rolling day; an empty asset means VAP
+func (s *SmartContract) GetRemainingAllowance(ctx contractapi.TransactionContextInterface, walletID string, asset string) (*SpendingAllowance, error) {
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := resolveAsset(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := getEffectiveSpendingLimit(ctx, wallet, token.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	window, err := getSpendWindow(ctx, walletID, token.Symbol, timestamp.Seconds)
+	if err != nil {
+		return nil, err
+	}
+
+	allowance := &SpendingAllowance{
+		WalletID:              walletID,
+		Asset:                 token.Symbol,
+		Limit:                 *limit,
+		RemainingAmount:       -1,
+		RemainingTransactions: -1,
+		WindowStart:           timestamp.Seconds - spendWindowSeconds,
+	}
+	for _, entry := range window.Entries {
+		allowance.SpentToday += entry.Amount
+		allowance.TransactionsToday++
+	}
+	if limit.MaxPerDay > 0 {
+		allowance.RemainingAmount = max(limit.MaxPerDay-allowance.SpentToday, 0)
+	}
+	if limit.MaxTransactionsPerDay > 0 {
+		allowance.RemainingTransactions = max(limit.MaxTransactionsPerDay-allowance.TransactionsToday, 0)
+	}
+
+	return allowance, nil
+}
+
+// consumeSpendingAllowance checks an outgoing amount of an asset against the
+// wallet's limits on that asset and records it in the rolling window
+func consumeSpendingAllowance(ctx contractapi.TransactionContextInterface, wallet *UserWallet, asset string, amount int64) error {
+	return addSpendEntry(ctx, wallet, asset, amount, "")
+}
+
+// addSpendEntry checks amount against the limits and records it, for holdID
+// when it reserves a hold
+func addSpendEntry(ctx contractapi.TransactionContextInterface, wallet *UserWallet, asset string, amount int64, holdID string) error {
+	limit, err := getEffectiveSpendingLimit(ctx, wallet, asset)
+	if err != nil {
+		return err
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	now := timestamp.Seconds
+
+	window, err := getSpendWindow(ctx, wallet.ID, asset, now)
+	if err != nil {
+		return err
+	}
+
+	if limit.MaxPerTransaction > 0 && amount > limit.MaxPerTransaction {
+		return newContractError(ErrCodeLimitExceeded, "amount %d exceeds the per-transaction limit of %d for wallet %s", amount, limit.MaxPerTransaction, wallet.ID)
+	}
+
+	var spent int64
+	for _, entry := range window.Entries {
+		spent += entry.Amount
+	}
+	if limit.MaxPerDay > 0 && spent+amount > limit.MaxPerDay {
+		return newContractError(ErrCodeLimitExceeded, "wallet %s would exceed its daily limit of %d (already spent %d)", wallet.ID, limit.MaxPerDay, spent)
+	}
+	if limit.MaxTransactionsPerDay > 0 && int64(len(window.Entries)) >= limit.MaxTransactionsPerDay {
+		return newContractError(ErrCodeLimitExceeded, "wallet %s has reached its limit of %d transactions per day", wallet.ID, limit.MaxTransactionsPerDay)
+	}
+
+	window.Entries = append(window.Entries, spendEntry{Timestamp: now, Amount: amount, HoldID: holdID})
+	return putSpendWindow(ctx, wallet.ID, asset, window)
+}
+
+// settleHoldSpending replaces the VAP window entries recorded when holds were
+// placed with the amounts finally paid, keyed by hold ID. Holds paid nothing
+// no longer count against the limits at all.
+func settleHoldSpending(ctx contractapi.TransactionContextInterface, walletID string, paid map[string]int64) error {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	window, err := getSpendWindow(ctx, walletID, DefaultAsset, timestamp.Seconds)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	entries := window.Entries[:0]
+	for _, entry := range window.Entries {
+		amount, ok := paid[entry.HoldID]
+		if entry.HoldID == "" || !ok {
+			entries = append(entries, entry)
+			continue
+		}
+		changed = true
+		if amount > 0 {
+			entry.Amount = amount
+			entries = append(entries, entry)
+		}
+	}
+	if !changed {
+		return nil
+	}
+	window.Entries = entries
+	return putSpendWindow(ctx, walletID, DefaultAsset, window)
+}
+
+// getEffectiveSpendingLimit returns the wallet override on an asset, else the
+// role default, else no limit
+func getEffectiveSpendingLimit(ctx contractapi.TransactionContextInterface, wallet *UserWallet, asset string) (*SpendingLimit, error) {
+	limit, err := getSpendingLimit(ctx, limitScopeWallet, wallet.ID, asset)
+	if err != nil || limit != nil {
+		return limit, err
+	}
+	limit, err = getSpendingLimit(ctx, limitScopeRole, wallet.Type, asset)
+	if err != nil || limit != nil {
+		return limit, err
+	}
+	return &SpendingLimit{}, nil
+}
+
+// assetKeyAttributes appends the asset to key attributes unless it is VAP,
+// whose keys predate registered assets
+func assetKeyAttributes(asset string, attributes ...string) []string {
+	if asset == "" || asset == DefaultAsset {
+		return attributes
+	}
+	return append(attributes, asset)
+}
+
+func getSpendingLimit(ctx contractapi.TransactionContextInterface, scope string, id string, asset string) (*SpendingLimit, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(limitObjectType, assetKeyAttributes(asset, scope, id))
+	if err != nil {
+		return nil, err
+	}
+	limitJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if limitJSON == nil {
+		return nil, nil
+	}
+
+	var limit SpendingLimit
+	err = json.Unmarshal(limitJSON, &limit)
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+func putSpendingLimit(ctx contractapi.TransactionContextInterface, scope string, id string, asset string, limit *SpendingLimit) error {
+	if limit.MaxPerTransaction < 0 || limit.MaxPerDay < 0 || limit.MaxTransactionsPerDay < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	limit.Source = scope
+
+	key, err := ctx.GetStub().CreateCompositeKey(limitObjectType, assetKeyAttributes(asset, scope, id))
+	if err != nil {
+		return err
+	}
+	limitJSON, err := json.Marshal(limit)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, limitJSON)
+}
+
+// getSpendWindow reads the wallet's rolling window for an asset, dropping
+// entries older than a day
+func getSpendWindow(ctx contractapi.TransactionContextInterface, walletID string, asset string, now int64) (*spendWindow, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(spendWindowObjectType, assetKeyAttributes(asset, walletID))
+	if err != nil {
+		return nil, err
+	}
+	windowJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	var window spendWindow
+	if windowJSON != nil {
+		err = json.Unmarshal(windowJSON, &window)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	recent := window.Entries[:0]
+	for _, entry := range window.Entries {
+		if entry.Timestamp > now-spendWindowSeconds {
+			recent = append(recent, entry)
+		}
+	}
+	window.Entries = recent
+
+	return &window, nil
+}
+
+func putSpendWindow(ctx contractapi.TransactionContextInterface, walletID string, asset string, window *spendWindow) error {
+	key, err := ctx.GetStub().CreateCompositeKey(spendWindowObjectType, assetKeyAttributes(asset, walletID))
+	if err != nil {
+		return err
+	}
+	windowJSON, err := json.Marshal(window)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, windowJSON)
+}
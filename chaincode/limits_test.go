@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDailyLimitRollsOver(t *testing.T) {
+	l, s := newSeededLedger(t)
+	start := l.now
+	if err := s.SetWalletSpendingLimit(l.admin(), "student1", 0, 5000, 3, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	student := testIdentity{role: "student", wallet: "student1"}
+	steps := []struct {
+		name    string
+		offset  int64
+		amount  int64
+		wantErr bool
+	}{
+		{"first payment", 0, 2000, false},
+		{"within the day", 3600, 2500, false},
+		{"over the daily amount", 7200, 1000, true},
+		{"up to the daily amount", 7200, 500, false},
+		{"over the daily count", 10800, 1, true},
+		{"first payment rolled out", spendWindowSeconds, 2000, false},
+		{"still over the amount", spendWindowSeconds + 1800, 1000, true},
+		{"all payments rolled out", 2*spendWindowSeconds + 7200, 3000, false},
+	}
+	for _, step := range steps {
+		l.now = start + step.offset
+		_, err := s.Transfer(l.tx(student), "student1", "merchant1", step.amount, "", "", "", "")
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: Transfer error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		var contractErr *ContractError
+		if err != nil && (!errors.As(err, &contractErr) || contractErr.Code != ErrCodeLimitExceeded) {
+			t.Fatalf("%s: error = %v, want %s", step.name, err, ErrCodeLimitExceeded)
+		}
+	}
+
+	allowance, err := s.GetRemainingAllowance(l.tx(student), "student1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowance.SpentToday != 3000 || allowance.RemainingAmount != 2000 || allowance.RemainingTransactions != 2 {
+		t.Errorf("allowance = %+v, want 3000 spent, 2000 and 2 transactions remaining", allowance)
+	}
+}
+
+func TestWalletLimitOverridesRoleLimit(t *testing.T) {
+	l, s := newSeededLedger(t)
+	if err := s.SetRoleSpendingLimit(l.admin(), "student", 1000, 0, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	student := testIdentity{role: "student", wallet: "student1"}
+	tests := []struct {
+		name    string
+		setup   func() error
+		source  string
+		wantErr bool
+	}{
+		{"role limit", func() error { return nil }, limitScopeRole, true},
+		{"wallet override", func() error { return s.SetWalletSpendingLimit(l.admin(), "student1", 2000, 0, 0, "") }, limitScopeWallet, false},
+		{"override cleared", func() error { return s.ClearWalletSpendingLimit(l.admin(), "student1", "") }, limitScopeRole, true},
+	}
+	for _, tt := range tests {
+		if err := tt.setup(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		limit, err := s.GetSpendingLimit(l.tx(student), "student1", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if limit.Source != tt.source {
+			t.Errorf("%s: limit source = %q, want %q", tt.name, limit.Source, tt.source)
+		}
+		if _, err := s.Transfer(l.tx(student), "student1", "merchant1", 1500, "", "", "", ""); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Transfer error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	maxMemoLength = 140
+	// referenceTxIndex lists the transactions carrying each external reference
+	referenceTxIndex = "ref~tx"
+)
+
+// transferCategories lists the categories accepted on transfers
+var transferCategories = map[string]bool{
+	"food":       true,
+	"printing":   true,
+	"transport":  true,
+	"stationery": true,
+	"events":     true,
+	"other":      true,
+}
+
+// TransferDetails are the optional public descriptive fields of a payment.
+// Memos are private, see private_details.go.
+type TransferDetails struct {
+	Category  string `json:"category,omitempty"`
+	Reference string `json:"reference,omitempty"`
+}
+
+// validate checks the lengths and characters of the text fields and the category
+func (d TransferDetails) validate() error {
+	if err := validateText("reference", d.Reference, maxReferenceLength); err != nil {
+		return err
+	}
+	if d.Category != "" && !transferCategories[d.Category] {
+		return fmt.Errorf("unknown category %q", d.Category)
+	}
+	return nil
+}
+
+// validateText checks that a free-text field is valid UTF-8 of at most
+// maxLength characters without control characters, which composite keys cannot hold
+func validateText(name string, value string, maxLength int) error {
+	if !utf8.ValidString(value) {
+		return fmt.Errorf("%s is not valid UTF-8", name)
+	}
+	if utf8.RuneCountInString(value) > maxLength {
+		return fmt.Errorf("%s must be at most %d characters", name, maxLength)
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%s must not contain control characters", name)
+		}
+	}
+	return nil
+}
+
+// apply copies the details onto a record
+func (d TransferDetails) apply(record *TransactionRecord) {
+	record.Category = d.Category
+	record.Reference = d.Reference
+}
+
+// GetTransactionsByReference returns the transactions carrying an external
+// reference, with pagination. Works on any state database.
+func (s *SmartContract) GetTransactionsByReference(ctx contractapi.TransactionContextInterface, reference string, pageSize int32, bookmark string) (*PaginatedResponse, error) {
+	if reference == "" {
+		return nil, fmt.Errorf("reference is required")
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(referenceTxIndex, []string{reference}, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records := []*TransactionRecord{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+
+		record, err := getTransactionRecord(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return &PaginatedResponse{
+		Records:      records,
+		Bookmark:     metadata.Bookmark,
+		RecordsCount: len(records),
+	}, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testIdentity is a client identity carrying the VapCoin attributes
+type testIdentity struct {
+	role   string
+	wallet string
+}
+
+func (i testIdentity) GetID() (string, error)    { return "x509::" + i.wallet, nil }
+func (i testIdentity) GetMSPID() (string, error) { return adminMSPID, nil }
+func (i testIdentity) GetAttributeValue(name string) (string, bool, error) {
+	switch name {
+	case roleAttribute:
+		return i.role, i.role != "", nil
+	case walletAttribute:
+		return i.wallet, i.wallet != "", nil
+	}
+	return "", false, nil
+}
+func (i testIdentity) AssertAttributeValue(string, string) error      { return nil }
+func (i testIdentity) GetX509Certificate() (*x509.Certificate, error) { return nil, nil }
+
+// testLedger runs transactions against a mock stub. Transactions are
+// stamped with now, or the wall clock while now is zero.
+type testLedger struct {
+	t    *testing.T
+	stub *shimtest.MockStub
+	txs  int
+	now  int64
+}
+
+func newTestLedger(t *testing.T) *testLedger {
+	return &testLedger{t: t, stub: shimtest.NewMockStub("vapcoin", nil)}
+}
+
+// tx starts a new transaction submitted by identity
+func (l *testLedger) tx(identity testIdentity) contractapi.TransactionContextInterface {
+	l.txs++
+	l.stub.TxID = fmt.Sprintf("tx%d", l.txs)
+	l.stub.TxTimestamp = timestamppb.Now()
+	if l.now != 0 {
+		l.stub.TxTimestamp = &timestamppb.Timestamp{Seconds: l.now}
+	}
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(l.stub)
+	ctx.SetClientIdentity(identity)
+	return ctx
+}
+
+// newSeededLedger returns a ledger seeded by InitLedger with a fixed clock
+func newSeededLedger(t *testing.T) (*testLedger, *SmartContract) {
+	l := newTestLedger(t)
+	l.now = 1760000000
+	s := &SmartContract{}
+	if err := s.InitLedger(l.admin()); err != nil {
+		t.Fatal(err)
+	}
+	return l, s
+}
+
+func (l *testLedger) admin() contractapi.TransactionContextInterface {
+	return l.tx(testIdentity{role: roleAdmin, wallet: "admin"})
+}
+
+// put stores a raw value, as an earlier chaincode version would have
+func (l *testLedger) put(key string, value string) {
+	l.t.Helper()
+	l.stub.TxID = "legacy"
+	if err := l.stub.PutState(key, []byte(value)); err != nil {
+		l.t.Fatal(err)
+	}
+}
+
+// wallet decodes a wallet as stored
+func (l *testLedger) wallet(id string) *UserWallet {
+	l.t.Helper()
+	var wallet UserWallet
+	if err := json.Unmarshal(l.stub.State[id], &wallet); err != nil {
+		l.t.Fatalf("decode wallet %s: %v", id, err)
+	}
+	return &wallet
+}
+
+func (l *testLedger) get(key string) map[string]interface{} {
+	l.t.Helper()
+	var fields map[string]interface{}
+	if err := json.Unmarshal(l.stub.State[key], &fields); err != nil {
+		l.t.Fatalf("decode %s: %v", key, err)
+	}
+	return fields
+}
+
+// seedLegacyLedger writes wallets and a record in the float64 layout
+func seedLegacyLedger(l *testLedger) {
+	l.put("admin", `{"id":"admin","balance":1000.25,"type":"admin"}`)
+	l.put("student1", `{"id":"student1","balance":12.5,"type":"student"}`)
+	l.put("merchant1", `{"id":"merchant1","balance":0,"type":"merchant"}`)
+	l.put("TX_t1", `{"txId":"t1","from":"admin","to":"student1","amount":12.5,"timestamp":1700000000,"type":"transfer"}`)
+}
+
+func TestMigrateToMinorUnitsConvertsLegacyValues(t *testing.T) {
+	l := newTestLedger(t)
+	seedLegacyLedger(l)
+	// Written by this version before the migration ran, already in minor units
+	l.put("student2", `{"id":"student2","balance":500,"type":"student","status":"active","displayName":"Asha"}`)
+
+	s := &SmartContract{}
+	if err := s.MigrateToMinorUnits(l.admin()); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]float64{"admin": 100025, "student1": 1250, "merchant1": 0, "student2": 500} {
+		if got := l.get(key)["balance"]; got != want {
+			t.Errorf("%s balance = %v, want %v", key, got, want)
+		}
+	}
+	if got := l.get("student2")["displayName"]; got != "Asha" {
+		t.Errorf("student2 displayName = %v, want it kept", got)
+	}
+	record := l.get("TX_t1")
+	if record["amount"] != float64(1250) || record["timestamp"] != float64(1700000000) || record["to"] != "student1" {
+		t.Errorf("TX_t1 = %v, want amount 1250 and the other fields kept", record)
+	}
+	if version := string(l.stub.State[schemaVersionKey]); version != currentSchemaVersion {
+		t.Errorf("schema version = %q, want %q", version, currentSchemaVersion)
+	}
+
+	if err := s.MigrateToMinorUnits(l.admin()); err == nil {
+		t.Error("second migration succeeded, want it refused")
+	}
+	if got := l.get("student1")["balance"]; got != float64(1250) {
+		t.Errorf("student1 balance = %v after a second run, want 1250", got)
+	}
+}
+
+func TestFundsFrozenUntilMigrated(t *testing.T) {
+	l := newTestLedger(t)
+	seedLegacyLedger(l)
+	s := &SmartContract{}
+
+	if _, err := s.Transfer(l.admin(), "admin", "student1", 100, "", "", "", ""); err == nil {
+		t.Fatal("transfer on an unmigrated ledger succeeded")
+	}
+	if got := l.get("admin")["balance"]; got != 1000.25 {
+		t.Fatalf("admin balance = %v, want the legacy value untouched", got)
+	}
+
+	if err := s.MigrateToMinorUnits(l.admin()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Transfer(l.admin(), "admin", "student1", 100, "", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.get("student1")["balance"]; got != float64(1350) {
+		t.Errorf("student1 balance = %v, want 1350", got)
+	}
+}
+
+func TestInitLedgerOnlyMarksEmptyLedgers(t *testing.T) {
+	s := &SmartContract{}
+
+	l := newTestLedger(t)
+	l.put("student1", `{"id":"student1","balance":12.5,"type":"student"}`)
+	if err := s.InitLedger(l.admin()); err == nil {
+		t.Error("InitLedger on a legacy ledger succeeded")
+	}
+	if _, ok := l.stub.State[schemaVersionKey]; ok {
+		t.Error("InitLedger marked a legacy ledger as migrated")
+	}
+	if _, ok := l.stub.State["admin"]; ok {
+		t.Error("InitLedger seeded a legacy ledger")
+	}
+
+	l = newTestLedger(t)
+	if err := s.InitLedger(l.admin()); err != nil {
+		t.Fatal(err)
+	}
+	if version := string(l.stub.State[schemaVersionKey]); version != currentSchemaVersion {
+		t.Errorf("schema version = %q, want %q", version, currentSchemaVersion)
+	}
+	if got := l.get("student1")["balance"]; got != float64(100*AmountScale) {
+		t.Errorf("student1 balance = %v, want %v", got, 100*AmountScale)
+	}
+}
+
+func TestReindexHistoryIndexesLegacyRecords(t *testing.T) {
+	l := newTestLedger(t)
+	seedLegacyLedger(l)
+
+	if err := (&SmartContract{}).ReindexHistory(l.admin()); err != nil {
+		t.Fatal(err)
+	}
+	for _, party := range []string{"admin", "student1"} {
+		key, _ := l.stub.CreateCompositeKey("user~tx", []string{party, "t1"})
+		if _, ok := l.stub.State[key]; !ok {
+			t.Errorf("no user~tx index for %s", party)
+		}
+	}
+
+	l.put("TX_bad", `{"txId":"bad","amount":"twelve"}`)
+	if err := (&SmartContract{}).ReindexHistory(l.admin()); err == nil {
+		t.Error("ReindexHistory skipped an undecodable record, want an error")
+	}
+}
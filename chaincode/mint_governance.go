@@ -0,0 +1,469 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Mint proposal kinds and states. Open proposals past ExpiresAt are reported
+// as expired and can no longer be approved or executed.
+const (
+	ProposalKindMint      = "mint"      // executed by Mint or ExecuteMint
+	ProposalKindApprovers = "approvers" // replaces the approver registry once approved
+
+	ProposalStatusPending  = "pending"
+	ProposalStatusApproved = "approved"
+	ProposalStatusExecuted = "executed"
+	ProposalStatusRejected = "rejected"
+	ProposalStatusExpired  = "expired"
+)
+
+const (
+	mintGovernanceObjectType = "mintgovernance"
+	mintProposalObjectType   = "mintproposal"
+	maxProposalTTL           = 30 * 24 * 60 * 60 // seconds
+	maxMintApprovers         = 20
+	minMintThreshold         = 2
+)
+
+// MintGovernance is the registry of identities that approve mint proposals.
+// A proposal needs Threshold approvals from the current Approvers.
+type MintGovernance struct {
+	Approvers []string `json:"approvers"` // client identity IDs, as read by the chaincode
+	Threshold int      `json:"threshold"`
+	UpdatedAt int64    `json:"updatedAt"`
+}
+
+// ProposalVote is one approver's approval or rejection
+type ProposalVote struct {
+	Approver string `json:"approver"`
+	Reason   string `json:"reason,omitempty"`
+	At       int64  `json:"at"`
+}
+
+// MintProposal asks the approvers to mint an amount, or to replace the
+// approver registry. Its ID is the transaction that created it.
+type MintProposal struct {
+	ID           string          `json:"id"`
+	Kind         string          `json:"kind"`
+	Amount       int64           `json:"amount,omitempty"` // minor units, mint proposals only
+	Asset        string          `json:"asset,omitempty"`  // empty for VAP
+	Approvers    []string        `json:"approvers,omitempty"`
+	Threshold    int             `json:"threshold,omitempty"` // approvers proposals only
+	Reason       string          `json:"reason"`
+	Proposer     string          `json:"proposer"`
+	Approvals    []*ProposalVote `json:"approvals"`
+	Rejections   []*ProposalVote `json:"rejections"`
+	Status       string          `json:"status"`
+	CreatedAt    int64           `json:"createdAt"`
+	ExpiresAt    int64           `json:"expiresAt"`
+	ClosedAt     int64           `json:"closedAt,omitempty"`
+	ExecutedTxID string          `json:"executedTxId,omitempty"`
+}
+
+// SetMintApprovers registers the first approvers when none are registered,
+// for instance right after deployment. Later changes need an approved
+// ProposeApprovers proposal. Approvers are the IDs GetCallerID returns for
+// each approver's own credentials, and at least two of them must approve
+// every proposal, so no single credential can mint. Admin only.
+func (s *SmartContract) SetMintApprovers(ctx contractapi.TransactionContextInterface, approvers []string, threshold int) (*MintGovernance, error) {
+	if _, err := requireAdmin(ctx, "SetMintApprovers"); err != nil {
+		return nil, err
+	}
+	existing, err := getMintGovernance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing.Approvers) > 0 {
+		return nil, newContractError(ErrCodeForbidden, "mint approvers are already registered, propose a change with ProposeApprovers")
+	}
+	if err := validateApprovers(approvers, threshold); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	governance := &MintGovernance{Approvers: approvers, Threshold: threshold, UpdatedAt: timestamp.Seconds}
+	if err := putMintGovernance(ctx, governance); err != nil {
+		return nil, err
+	}
+
+	return governance, emitEvent(ctx, EventMintApproversChanged, LedgerEvent{Governance: governance})
+}
+
+// GetCallerID returns the client identity ID of the caller, the value to
+// register as a mint approver
+func (s *SmartContract) GetCallerID(ctx contractapi.TransactionContextInterface) (string, error) {
+	c, err := getCaller(ctx)
+	if err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+// GetMintGovernance returns the approver registry
+func (s *SmartContract) GetMintGovernance(ctx contractapi.TransactionContextInterface) (*MintGovernance, error) {
+	return getMintGovernance(ctx)
+}
+
+// ProposeMint opens a proposal to mint amount of asset, empty for VAP. It
+// can be approved and executed until expiresAt, a Unix timestamp in seconds
+// at most 30 days away. Admin only.
+func (s *SmartContract) ProposeMint(ctx contractapi.TransactionContextInterface, amount int64, asset string, reason string, expiresAt int64) (*MintProposal, error) {
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+	token, err := resolveAsset(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+	if err := token.validatePrecision(amount); err != nil {
+		return nil, err
+	}
+	if token.Symbol == DefaultAsset {
+		asset = ""
+	}
+
+	return openProposal(ctx, "ProposeMint", &MintProposal{Kind: ProposalKindMint, Amount: amount, Asset: asset}, reason, expiresAt)
+}
+
+// ProposeApprovers opens a proposal to replace the approver registry. It
+// takes effect as soon as the current approvers approve it. Admin only.
+func (s *SmartContract) ProposeApprovers(ctx contractapi.TransactionContextInterface, approvers []string, threshold int, reason string, expiresAt int64) (*MintProposal, error) {
+	if err := validateApprovers(approvers, threshold); err != nil {
+		return nil, err
+	}
+
+	return openProposal(ctx, "ProposeApprovers", &MintProposal{Kind: ProposalKindApprovers, Approvers: approvers, Threshold: threshold}, reason, expiresAt)
+}
+
+// ApproveMint records the caller's approval of an open proposal. Once the
+// threshold is reached a mint proposal becomes approved and can be executed,
+// and an approvers proposal replaces the registry. Registered approvers only.
+func (s *SmartContract) ApproveMint(ctx contractapi.TransactionContextInterface, proposalID string) (*MintProposal, error) {
+	c, governance, proposal, err := loadProposalVote(ctx, "ApproveMint", proposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	vote, err := newProposalVote(ctx, c.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	proposal.Approvals = append(proposal.Approvals, vote)
+	if governance.countApprovals(proposal) >= governance.Threshold {
+		proposal.Status = ProposalStatusApproved
+	}
+
+	if proposal.Kind == ProposalKindApprovers && proposal.Status == ProposalStatusApproved {
+		governance = &MintGovernance{Approvers: proposal.Approvers, Threshold: proposal.Threshold, UpdatedAt: vote.At}
+		if err := putMintGovernance(ctx, governance); err != nil {
+			return nil, err
+		}
+		proposal.Status = ProposalStatusExecuted
+		proposal.ExecutedTxID = ctx.GetStub().GetTxID()
+		proposal.ClosedAt = vote.At
+		if err := putProposal(ctx, proposal); err != nil {
+			return nil, err
+		}
+		return proposal, emitEvent(ctx, EventMintApproversChanged, LedgerEvent{Proposal: proposal, Governance: governance})
+	}
+
+	if err := putProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	return proposal, emitEvent(ctx, EventMintApproved, LedgerEvent{Proposal: proposal})
+}
+
+// RejectMint records the caller's rejection of an open proposal. The
+// proposal is rejected once too few approvers remain to reach the threshold.
+// Registered approvers only.
+func (s *SmartContract) RejectMint(ctx contractapi.TransactionContextInterface, proposalID string, reason string) (*MintProposal, error) {
+	if err := validateText("reason", reason, maxReferenceLength); err != nil {
+		return nil, err
+	}
+	c, governance, proposal, err := loadProposalVote(ctx, "RejectMint", proposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	vote, err := newProposalVote(ctx, c.ID, reason)
+	if err != nil {
+		return nil, err
+	}
+	proposal.Rejections = append(proposal.Rejections, vote)
+	rejected := 0
+	for _, vote := range proposal.Rejections {
+		if governance.isApprover(vote.Approver) {
+			rejected++
+		}
+	}
+	if len(governance.Approvers)-rejected < governance.Threshold {
+		proposal.Status = ProposalStatusRejected
+		proposal.ClosedAt = vote.At
+	}
+	if err := putProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+
+	return proposal, emitEvent(ctx, EventMintRejected, LedgerEvent{Proposal: proposal})
+}
+
+// ExecuteMint mints the amount of an approved mint proposal. It is Mint
+// without an idempotency key; a proposal is only ever executed once. Admin only.
+func (s *SmartContract) ExecuteMint(ctx contractapi.TransactionContextInterface, proposalID string) (string, error) {
+	return s.Mint(ctx, proposalID, "")
+}
+
+// GetMintProposal returns a proposal, reporting open proposals past their expiry as expired
+func (s *SmartContract) GetMintProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*MintProposal, error) {
+	proposal, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	return proposal, markExpiredProposal(ctx, proposal)
+}
+
+// GetAllMintProposals returns every proposal in ID order
+func (s *SmartContract) GetAllMintProposals(ctx contractapi.TransactionContextInterface) ([]*MintProposal, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(mintProposalObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	proposals := []*MintProposal{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var proposal MintProposal
+		err = json.Unmarshal(response.Value, &proposal)
+		if err != nil {
+			return nil, err
+		}
+		if err := markExpiredProposal(ctx, &proposal); err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, &proposal)
+	}
+
+	return proposals, nil
+}
+
+// openProposal fills in and stores a new proposal created by an admin
+func openProposal(ctx contractapi.TransactionContextInterface, action string, proposal *MintProposal, reason string, expiresAt int64) (*MintProposal, error) {
+	c, err := requireAdmin(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+	if err := validateText("reason", reason, maxReferenceLength); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt <= timestamp.Seconds || expiresAt > timestamp.Seconds+maxProposalTTL {
+		return nil, fmt.Errorf("expiry must be in the future and at most %d days away", maxProposalTTL/(24*60*60))
+	}
+
+	proposal.ID = ctx.GetStub().GetTxID()
+	proposal.Reason = reason
+	proposal.Proposer = c.ID
+	proposal.Approvals = []*ProposalVote{}
+	proposal.Rejections = []*ProposalVote{}
+	proposal.Status = ProposalStatusPending
+	proposal.CreatedAt = timestamp.Seconds
+	proposal.ExpiresAt = expiresAt
+	if err := putProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+
+	return proposal, emitEvent(ctx, EventMintProposed, LedgerEvent{Proposal: proposal})
+}
+
+// loadProposalVote checks that the caller is a registered approver who has
+// not voted on the open proposal yet
+func loadProposalVote(ctx contractapi.TransactionContextInterface, action string, proposalID string) (*caller, *MintGovernance, *MintProposal, error) {
+	c, err := requireAdmin(ctx, action)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	governance, err := getMintGovernance(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !governance.isApprover(c.ID) {
+		return nil, nil, nil, newContractError(ErrCodeForbidden, "caller %s is not a registered mint approver", c.ID)
+	}
+
+	proposal, err := getOpenProposal(ctx, proposalID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, vote := range append(proposal.Approvals, proposal.Rejections...) {
+		if vote.Approver == c.ID {
+			return nil, nil, nil, fmt.Errorf("caller %s has already voted on proposal %s", c.ID, proposalID)
+		}
+	}
+	return c, governance, proposal, nil
+}
+
+func newProposalVote(ctx contractapi.TransactionContextInterface, approver string, reason string) (*ProposalVote, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	return &ProposalVote{Approver: approver, Reason: reason, At: timestamp.Seconds}, nil
+}
+
+// getOpenProposal returns a pending or approved proposal that has not expired
+func getOpenProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*MintProposal, error) {
+	proposal, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if err := markExpiredProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	if proposal.Status != ProposalStatusPending && proposal.Status != ProposalStatusApproved {
+		return nil, newContractError(ErrCodeProposalNotOpen, "proposal %s is %s", proposalID, proposal.Status)
+	}
+	return proposal, nil
+}
+
+// markExpiredProposal reports an open proposal past its expiry as expired.
+// The stored proposal is left as it is.
+func markExpiredProposal(ctx contractapi.TransactionContextInterface, proposal *MintProposal) error {
+	if proposal.Status != ProposalStatusPending && proposal.Status != ProposalStatusApproved {
+		return nil
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	if timestamp.Seconds >= proposal.ExpiresAt {
+		proposal.Status = ProposalStatusExpired
+	}
+	return nil
+}
+
+// countApprovals counts the approvals of identities that are still approvers
+func (g *MintGovernance) countApprovals(proposal *MintProposal) int {
+	approvals := 0
+	for _, vote := range proposal.Approvals {
+		if g.isApprover(vote.Approver) {
+			approvals++
+		}
+	}
+	return approvals
+}
+
+func (g *MintGovernance) isApprover(id string) bool {
+	for _, approver := range g.Approvers {
+		if approver == id {
+			return true
+		}
+	}
+	return false
+}
+
+// validateApprovers requires distinct identities and a threshold of at
+// least two, so that no single credential can approve a proposal
+func validateApprovers(approvers []string, threshold int) error {
+	if len(approvers) > maxMintApprovers {
+		return fmt.Errorf("at most %d approvers can be registered", maxMintApprovers)
+	}
+	seen := make(map[string]bool)
+	for _, approver := range approvers {
+		if approver == "" {
+			return fmt.Errorf("approver identities must not be empty")
+		}
+		if seen[approver] {
+			return fmt.Errorf("approver %s is listed twice", approver)
+		}
+		seen[approver] = true
+	}
+	if threshold < minMintThreshold || threshold > len(approvers) {
+		return fmt.Errorf("threshold must be between %d and the number of approvers (%d)", minMintThreshold, len(approvers))
+	}
+	return nil
+}
+
+// getMintGovernance reads the approver registry, which is empty until SetMintApprovers
+func getMintGovernance(ctx contractapi.TransactionContextInterface) (*MintGovernance, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(mintGovernanceObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	governanceJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if governanceJSON == nil {
+		return &MintGovernance{Approvers: []string{}}, nil
+	}
+
+	var governance MintGovernance
+	err = json.Unmarshal(governanceJSON, &governance)
+	if err != nil {
+		return nil, err
+	}
+	return &governance, nil
+}
+
+func putMintGovernance(ctx contractapi.TransactionContextInterface, governance *MintGovernance) error {
+	key, err := ctx.GetStub().CreateCompositeKey(mintGovernanceObjectType, []string{})
+	if err != nil {
+		return err
+	}
+	governanceJSON, err := json.Marshal(governance)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, governanceJSON)
+}
+
+func getProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*MintProposal, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(mintProposalObjectType, []string{proposalID})
+	if err != nil {
+		return nil, err
+	}
+	proposalJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if proposalJSON == nil {
+		return nil, newContractError(ErrCodeNotFound, "mint proposal %s does not exist", proposalID)
+	}
+
+	var proposal MintProposal
+	err = json.Unmarshal(proposalJSON, &proposal)
+	if err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+func putProposal(ctx contractapi.TransactionContextInterface, proposal *MintProposal) error {
+	key, err := ctx.GetStub().CreateCompositeKey(mintProposalObjectType, []string{proposal.ID})
+	if err != nil {
+		return err
+	}
+	proposalJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, proposalJSON)
+}
@@ -0,0 +1,143 @@
+package main
+
+import "testing"
+
+// vote is one approver's vote in a governance test
+type vote struct {
+	approver string
+	approve  bool
+}
+
+func castVote(l *testLedger, s *SmartContract, proposalID string, v vote) (*MintProposal, error) {
+	if v.approve {
+		return s.ApproveMint(l.tx(approverIdentity(v.approver)), proposalID)
+	}
+	return s.RejectMint(l.tx(approverIdentity(v.approver)), proposalID, "not needed")
+}
+
+func TestMintProposalThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		votes      []vote
+		wantCode   string // of the last vote
+		wantStatus string
+	}{
+		{"one approval", []vote{{"approver1", true}}, "", ProposalStatusPending},
+		{"threshold reached", []vote{{"approver1", true}, {"approver2", true}}, "", ProposalStatusApproved},
+		{"one rejection", []vote{{"approver1", false}}, "", ProposalStatusPending},
+		{"too few left to approve", []vote{{"approver1", false}, {"approver2", false}}, "", ProposalStatusRejected},
+		{"approved despite a rejection", []vote{{"approver1", true}, {"approver2", false}, {"approver3", true}}, "", ProposalStatusApproved},
+		{"vote after rejection", []vote{{"approver1", false}, {"approver2", false}, {"approver3", true}}, ErrCodeProposalNotOpen, ProposalStatusRejected},
+		{"not an approver", []vote{{"admin", true}}, ErrCodeForbidden, ProposalStatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, s := newSeededLedger(t)
+			registerApprovers(t, l, s, 2, "approver1", "approver2", "approver3")
+			proposal, err := s.ProposeMint(l.admin(), 5000, "", "term top-up", l.now+3600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, v := range tt.votes {
+				_, err := castVote(l, s, proposal.ID, v)
+				wantCode := ""
+				if i == len(tt.votes)-1 {
+					wantCode = tt.wantCode
+				}
+				if got := errorCode(err); got != wantCode || (err != nil && wantCode == "") {
+					t.Fatalf("vote %d: error = %v, want %q", i+1, err, wantCode)
+				}
+			}
+			got, err := s.GetMintProposal(l.admin(), proposal.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+
+			admin := l.wallet("admin").Balance
+			_, err = s.ExecuteMint(l.admin(), proposal.ID)
+			if minted := err == nil; minted != (tt.wantStatus == ProposalStatusApproved) {
+				t.Errorf("ExecuteMint error = %v with status %s", err, tt.wantStatus)
+			}
+			if err == nil && l.wallet("admin").Balance != admin+5000 {
+				t.Errorf("admin balance = %d, want %d", l.wallet("admin").Balance, admin+5000)
+			}
+		})
+	}
+}
+
+func TestApproverVotesOnce(t *testing.T) {
+	l, s := newSeededLedger(t)
+	registerApprovers(t, l, s, 2, "approver1", "approver2", "approver3")
+	proposal, err := s.ProposeMint(l.admin(), 5000, "", "term top-up", l.now+3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := castVote(l, s, proposal.ID, vote{"approver1", true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []vote{{"approver1", true}, {"approver1", false}} {
+		if _, err := castVote(l, s, proposal.ID, v); err == nil {
+			t.Errorf("approver1 voted twice (approve %v)", v.approve)
+		}
+	}
+
+	l.now = proposal.ExpiresAt
+	if _, err := castVote(l, s, proposal.ID, vote{"approver2", true}); errorCode(err) != ErrCodeProposalNotOpen {
+		t.Errorf("vote on an expired proposal error = %v, want %s", err, ErrCodeProposalNotOpen)
+	}
+}
+
+func TestRegistryReplacementWhileProposalsArePending(t *testing.T) {
+	l, s := newSeededLedger(t)
+	registerApprovers(t, l, s, 2, "approver1", "approver2", "approver3")
+	mint, err := s.ProposeMint(l.admin(), 5000, "", "term top-up", l.now+3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := castVote(l, s, mint.ID, vote{"approver1", true}); err != nil {
+		t.Fatal(err)
+	}
+
+	newApprover, _ := approverIdentity("approver4").GetID()
+	keptApprover, _ := approverIdentity("approver2").GetID()
+	change, err := s.ProposeApprovers(l.admin(), []string{keptApprover, newApprover}, 2, "approver1 left", l.now+3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, approver := range []string{"approver1", "approver3"} {
+		if _, err := castVote(l, s, change.ID, vote{approver, true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	governance, err := s.GetMintGovernance(l.admin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(governance.Approvers) != 2 || governance.Approvers[1] != newApprover {
+		t.Fatalf("approvers = %v, want the proposed registry", governance.Approvers)
+	}
+
+	steps := []struct {
+		name       string
+		vote       vote
+		wantErr    bool
+		wantStatus string
+	}{
+		{"removed approver", vote{"approver1", true}, true, ProposalStatusPending},
+		{"first current approval", vote{"approver2", true}, false, ProposalStatusPending},
+		{"second current approval", vote{"approver4", true}, false, ProposalStatusApproved},
+	}
+	for _, step := range steps {
+		proposal, err := castVote(l, s, mint.ID, step.vote)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		if err == nil && proposal.Status != step.wantStatus {
+			t.Errorf("%s: status = %s, want %s; approver1's earlier approval must not count", step.name, proposal.Status, step.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Payment request states. Expiry is evaluated lazily: an open request past
+// its ExpiresAt is reported as expired without a separate transaction.
+const (
+	RequestStatusOpen      = "open"
+	RequestStatusPaid      = "paid"
+	RequestStatusExpired   = "expired"
+	RequestStatusCancelled = "cancelled"
+)
+
+const (
+	paymentRequestObjectType = "payreq"
+	merchantRequestIndex     = "merchant~payreq"
+	maxReferenceLength       = 64
+)
+
+// PaymentRequest is an invoice created by a merchant and paid by reference
+type PaymentRequest struct {
+	ID          string `json:"id"`
+	MerchantID  string `json:"merchantId"`
+	Amount      int64  `json:"amount"` // minor units
+	Reference   string `json:"reference"`
+	Status      string `json:"status"`
+	CreatedAt   int64  `json:"createdAt"`
+	ExpiresAt   int64  `json:"expiresAt"`
+	PaidBy      string `json:"paidBy,omitempty"`
+	PaidAt      int64  `json:"paidAt,omitempty"`
+	PaymentTxID string `json:"paymentTxId,omitempty"`
+}
+
+// PaginatedPaymentRequests describes a page of payment requests
+type PaginatedPaymentRequests struct {
+	Requests     []*PaymentRequest `json:"requests"`
+	Bookmark     string            `json:"bookmark"`
+	RecordsCount int               `json:"recordsCount"`
+}
+
+// CreatePaymentRequest opens a request for a fixed amount. The request ID is
+// the creating transaction's ID. expiry is a Unix timestamp in seconds.
+func (s *SmartContract) CreatePaymentRequest(ctx contractapi.TransactionContextInterface, merchantID string, amount int64, reference string, expiry int64) (*PaymentRequest, error) {
+	if _, err := requireWalletAccess(ctx, merchantID); err != nil {
+		return nil, err
+	}
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+	if reference == "" {
+		return nil, fmt.Errorf("reference is required")
+	}
+	if err := validateText("reference", reference, maxReferenceLength); err != nil {
+		return nil, err
+	}
+
+	merchant, err := getWallet(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.Type != "merchant" {
+		return nil, newContractError(ErrCodeForbidden, "only merchant wallets can create payment requests, %s is a %s wallet", merchantID, merchant.Type)
+	}
+	if err := merchant.RequireActive(); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if expiry <= timestamp.Seconds {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+
+	request := &PaymentRequest{
+		ID:         ctx.GetStub().GetTxID(),
+		MerchantID: merchantID,
+		Amount:     amount,
+		Reference:  reference,
+		Status:     RequestStatusOpen,
+		CreatedAt:  timestamp.Seconds,
+		ExpiresAt:  expiry,
+	}
+	if err := putPaymentRequest(ctx, request); err != nil {
+		return nil, err
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(merchantRequestIndex, []string{merchantID, request.ID})
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, emitEvent(ctx, EventPaymentRequestCreated, LedgerEvent{PaymentRequest: request})
+}
+
+// PayRequest pays an open request in full from payerID to the merchant
+func (s *SmartContract) PayRequest(ctx contractapi.TransactionContextInterface, requestID string, payerID string) (*PaymentRequest, error) {
+	if _, err := requireWalletAccess(ctx, payerID); err != nil {
+		return nil, err
+	}
+
+	request, err := getPaymentRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	switch request.Status {
+	case RequestStatusOpen:
+	case RequestStatusExpired:
+		return nil, newContractError(ErrCodeRequestExpired, "payment request %s expired at %d", requestID, request.ExpiresAt)
+	default:
+		return nil, newContractError(ErrCodeRequestNotOpen, "payment request %s is %s", requestID, request.Status)
+	}
+
+	record, fee, err := transfer(ctx, payerID, request.MerchantID, request.Amount, "transfer")
+	if err != nil {
+		return nil, err
+	}
+	record.RequestID = request.ID
+	record.Reference = request.Reference
+	if err := putTransactionRecord(ctx, record); err != nil {
+		return nil, err
+	}
+
+	request.Status = RequestStatusPaid
+	request.PaidBy = payerID
+	request.PaidAt = record.Timestamp
+	request.PaymentTxID = record.TxID
+	if err := putPaymentRequest(ctx, request); err != nil {
+		return nil, err
+	}
+
+	return request, emitEvent(ctx, EventTransfer, LedgerEvent{Record: record, Fee: fee, PaymentRequest: request})
+}
+
+// CancelPaymentRequest withdraws an open request. Only the merchant may cancel.
+func (s *SmartContract) CancelPaymentRequest(ctx contractapi.TransactionContextInterface, requestID string) (*PaymentRequest, error) {
+	request, err := getPaymentRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := requireWalletAccess(ctx, request.MerchantID); err != nil {
+		return nil, err
+	}
+	if request.Status != RequestStatusOpen {
+		return nil, newContractError(ErrCodeRequestNotOpen, "payment request %s is %s", requestID, request.Status)
+	}
+
+	request.Status = RequestStatusCancelled
+	if err := putPaymentRequest(ctx, request); err != nil {
+		return nil, err
+	}
+
+	return request, emitEvent(ctx, EventPaymentRequestCancelled, LedgerEvent{PaymentRequest: request})
+}
+
+// GetPaymentRequest returns a payment request by ID
+func (s *SmartContract) GetPaymentRequest(ctx contractapi.TransactionContextInterface, requestID string) (*PaymentRequest, error) {
+	return getPaymentRequest(ctx, requestID)
+}
+
+// GetPaymentRequestsByMerchant returns a merchant's payment requests with pagination
+func (s *SmartContract) GetPaymentRequestsByMerchant(ctx contractapi.TransactionContextInterface, merchantID string, pageSize int32, bookmark string) (*PaginatedPaymentRequests, error) {
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(merchantRequestIndex, []string{merchantID}, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var requests []*PaymentRequest
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+
+		request, err := getPaymentRequest(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+
+	return &PaginatedPaymentRequests{
+		Requests:     requests,
+		Bookmark:     metadata.Bookmark,
+		RecordsCount: len(requests),
+	}, nil
+}
+
+// getPaymentRequest reads a request, reporting open requests past their expiry as expired
+func getPaymentRequest(ctx contractapi.TransactionContextInterface, requestID string) (*PaymentRequest, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(paymentRequestObjectType, []string{requestID})
+	if err != nil {
+		return nil, err
+	}
+	requestJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if requestJSON == nil {
+		return nil, newContractError(ErrCodeNotFound, "payment request %s does not exist", requestID)
+	}
+
+	var request PaymentRequest
+	err = json.Unmarshal(requestJSON, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Status == RequestStatusOpen {
+		timestamp, err := ctx.GetStub().GetTxTimestamp()
+		if err != nil {
+			return nil, err
+		}
+		if timestamp.Seconds > request.ExpiresAt {
+			request.Status = RequestStatusExpired
+		}
+	}
+
+	return &request, nil
+}
+
+func putPaymentRequest(ctx contractapi.TransactionContextInterface, request *PaymentRequest) error {
+	key, err := ctx.GetStub().CreateCompositeKey(paymentRequestObjectType, []string{request.ID})
+	if err != nil {
+		return err
+	}
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, requestJSON)
+}
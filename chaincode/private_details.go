@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Private data collections, defined in collections_config.json. Only member
+// organizations of a collection hold its values; every channel member sees
+// the hashes recorded on the public wallet or transaction record. Each value
+// carries a random salt passed by the client, so the hash of a guessable
+// value such as a student number cannot be found by trying candidates.
+const (
+	walletDetailsCollection   = "walletDetailsCollection"
+	transferDetailsCollection = "transferDetailsCollection"
+	// Transient map keys the private values are passed under, so they never
+	// appear in the transaction proposal written to the block
+	walletDetailsTransientKey   = "walletDetails"
+	transferDetailsTransientKey = "transferDetails"
+	// saltTransientKey carries the raw random bytes salting the private value
+	saltTransientKey = "salt"
+	minSaltLength    = 16
+	maxNoteLength    = 500
+)
+
+// WalletDetails is the personally identifying information of a wallet owner
+type WalletDetails struct {
+	WalletID      string `json:"walletId"`
+	FullName      string `json:"fullName,omitempty"`
+	Email         string `json:"email,omitempty"`
+	Phone         string `json:"phone,omitempty"`
+	StudentNumber string `json:"studentNumber,omitempty"` // university registration number
+	UpdatedAt     int64  `json:"updatedAt"`
+	Salt          string `json:"salt"` // hex, see readSalt
+}
+
+// PrivateTransferDetails are the optional details of a payment kept off the public record
+type PrivateTransferDetails struct {
+	TxID string `json:"txId"`
+	Memo string `json:"memo,omitempty"`
+	Note string `json:"note,omitempty"`
+	Salt string `json:"salt"` // hex, see readSalt
+}
+
+// SetWalletDetails stores the owner details passed as JSON under the
+// "walletDetails" transient key in the private collection, salted with the
+// "salt" transient field, and records their hash on the wallet. Owner or
+// admin only.
+func (s *SmartContract) SetWalletDetails(ctx contractapi.TransactionContextInterface, walletID string) (*UserWallet, error) {
+	if _, err := requireWalletAccess(ctx, walletID); err != nil {
+		return nil, err
+	}
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.CurrentStatus() == WalletStatusClosed {
+		return nil, newContractError(ErrCodeWalletClosed, "wallet %s is closed", walletID)
+	}
+
+	var details WalletDetails
+	found, err := readTransient(ctx, walletDetailsTransientKey, &details)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("wallet details must be passed in the %q transient field", walletDetailsTransientKey)
+	}
+	if err := details.validate(); err != nil {
+		return nil, err
+	}
+	details.Salt, err = readSalt(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	details.WalletID = walletID
+	details.UpdatedAt = timestamp.Seconds
+
+	wallet.DetailsHash, err = putPrivateJSON(ctx, walletDetailsCollection, walletID, details)
+	if err != nil {
+		return nil, err
+	}
+	return wallet, putWallet(ctx, wallet)
+}
+
+// GetWalletDetails returns the private owner details of a wallet. Owner or
+// admin only, and only on peers of organizations in the collection.
+func (s *SmartContract) GetWalletDetails(ctx contractapi.TransactionContextInterface, walletID string) (*WalletDetails, error) {
+	if _, err := requireWalletAccess(ctx, walletID); err != nil {
+		return nil, err
+	}
+
+	detailsJSON, err := ctx.GetStub().GetPrivateData(walletDetailsCollection, walletID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private data: %v", err)
+	}
+	if detailsJSON == nil {
+		return nil, newContractError(ErrCodeNotFound, "wallet %s has no private details", walletID)
+	}
+
+	var details WalletDetails
+	err = json.Unmarshal(detailsJSON, &details)
+	if err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// GetTransferDetails returns the private details of a payment. Only the
+// sender, the receiver or an admin may read them.
+func (s *SmartContract) GetTransferDetails(ctx contractapi.TransactionContextInterface, txID string) (*PrivateTransferDetails, error) {
+	record, err := getTransactionRecord(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := requireWalletAccess(ctx, record.From); err != nil {
+		if _, err := requireWalletAccess(ctx, record.To); err != nil {
+			return nil, err
+		}
+	}
+
+	detailsJSON, err := ctx.GetStub().GetPrivateData(transferDetailsCollection, txID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private data: %v", err)
+	}
+	if detailsJSON == nil {
+		return nil, newContractError(ErrCodeNotFound, "transaction %s has no private details", txID)
+	}
+
+	var details PrivateTransferDetails
+	err = json.Unmarshal(detailsJSON, &details)
+	if err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// readTransferDetails returns the private payment details passed under the
+// "transferDetails" transient key, salted with the "salt" transient field, or
+// nil when the caller passed none
+func readTransferDetails(ctx contractapi.TransactionContextInterface) (*PrivateTransferDetails, error) {
+	var details PrivateTransferDetails
+	found, err := readTransient(ctx, transferDetailsTransientKey, &details)
+	if err != nil || !found {
+		return nil, err
+	}
+	if err := validateText("memo", details.Memo, maxMemoLength); err != nil {
+		return nil, err
+	}
+	if err := validateText("note", details.Note, maxNoteLength); err != nil {
+		return nil, err
+	}
+	if details.Memo == "" && details.Note == "" {
+		return nil, nil
+	}
+	details.Salt, err = readSalt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// putTransferDetails stores private payment details for a record and sets
+// the record's DetailsHash. Nil details leave the record unchanged.
+func putTransferDetails(ctx contractapi.TransactionContextInterface, record *TransactionRecord, details *PrivateTransferDetails) error {
+	if details == nil {
+		return nil
+	}
+	details.TxID = record.TxID
+
+	hash, err := putPrivateJSON(ctx, transferDetailsCollection, record.TxID, details)
+	if err != nil {
+		return err
+	}
+	record.DetailsHash = hash
+	return nil
+}
+
+// validate checks the lengths and characters of the owner details
+func (d WalletDetails) validate() error {
+	fields := []struct{ name, value string }{
+		{"fullName", d.FullName},
+		{"email", d.Email},
+		{"phone", d.Phone},
+		{"studentNumber", d.StudentNumber},
+	}
+	for _, field := range fields {
+		if err := validateText(field.name, field.value, maxReferenceLength); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTransient decodes the JSON value of a transient field into v, reporting
+// whether the field was passed
+func readTransient(ctx contractapi.TransactionContextInterface, key string, v interface{}) (bool, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return false, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	value, ok := transient[key]
+	if !ok || len(value) == 0 {
+		return false, nil
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		return false, fmt.Errorf("invalid %s transient field: %v", key, err)
+	}
+	return true, nil
+}
+
+// readSalt returns the hex of the random bytes passed under the "salt"
+// transient key. The chaincode cannot draw the salt itself, since every
+// endorser must write the same value.
+func readSalt(ctx contractapi.TransactionContextInterface) (string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transient data: %v", err)
+	}
+	salt := transient[saltTransientKey]
+	if len(salt) < minSaltLength {
+		return "", fmt.Errorf("private details must be salted with at least %d random bytes in the %q transient field", minSaltLength, saltTransientKey)
+	}
+	return hex.EncodeToString(salt), nil
+}
+
+// putPrivateJSON stores v in a private collection and returns the hex SHA-256
+// of the stored bytes. The value includes its salt, so a verifier who reads
+// it with GetWalletDetails or GetTransferDetails hashes the JSON returned,
+// salt included, and compares it with the public hash; without the value
+// the hash reveals nothing.
+func putPrivateJSON(ctx contractapi.TransactionContextInterface, collection string, key string, v interface{}) (string, error) {
+	valueJSON, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.GetStub().PutPrivateData(collection, key, valueJSON); err != nil {
+		return "", fmt.Errorf("failed to write private data: %v", err)
+	}
+	sum := sha256.Sum256(valueJSON)
+	return hex.EncodeToString(sum[:]), nil
+}
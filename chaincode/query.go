@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxQueryPageSize caps QueryTransactions pages so a query cannot pull the whole ledger
+const maxQueryPageSize = 200
+
+// TransactionFilter selects transaction records for QueryTransactions.
+// Empty or zero fields do not filter. Times are Unix seconds and amounts
+// minor units; all ranges are inclusive.
+type TransactionFilter struct {
+	Type      string `json:"type,omitempty"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	Category  string `json:"category,omitempty"`
+	Reference string `json:"reference,omitempty"`
+	Asset     string `json:"asset,omitempty"` // token symbol; VAP matches records without one
+	StartTime int64  `json:"startTime,omitempty"`
+	EndTime   int64  `json:"endTime,omitempty"`
+	MinAmount int64  `json:"minAmount,omitempty"`
+	MaxAmount int64  `json:"maxAmount,omitempty"`
+}
+
+// QueryTransactions searches transaction records with a CouchDB rich query.
+// filterJSON is a JSON TransactionFilter; an empty string matches every record.
+// The indexes under META-INF/statedb/couchdb/indexes cover each filter field
+// together with the timestamp. Requires a peer using CouchDB as its state database.
+func (s *SmartContract) QueryTransactions(ctx contractapi.TransactionContextInterface, filterJSON string, pageSize int32, bookmark string) (*PaginatedResponse, error) {
+	if pageSize <= 0 || pageSize > maxQueryPageSize {
+		return nil, fmt.Errorf("page size must be between 1 and %d", maxQueryPageSize)
+	}
+
+	var filter TransactionFilter
+	if filterJSON != "" {
+		if err := json.Unmarshal([]byte(filterJSON), &filter); err != nil {
+			return nil, fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+	query, err := buildTransactionQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records := []*TransactionRecord{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var record TransactionRecord
+		err = json.Unmarshal(queryResponse.Value, &record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+
+	return &PaginatedResponse{
+		Records:      records,
+		Bookmark:     metadata.Bookmark,
+		RecordsCount: len(records),
+	}, nil
+}
+
+// buildTransactionQuery turns a filter into a CouchDB selector. Only
+// transaction records have a txId, which keeps wallets and other objects out.
+func buildTransactionQuery(filter TransactionFilter) (string, error) {
+	if filter.StartTime != 0 && filter.EndTime != 0 && filter.StartTime > filter.EndTime {
+		return "", fmt.Errorf("start time is after end time")
+	}
+	if filter.MinAmount < 0 || filter.MaxAmount < 0 {
+		return "", fmt.Errorf("amounts cannot be negative")
+	}
+	if filter.MaxAmount != 0 && filter.MinAmount > filter.MaxAmount {
+		return "", fmt.Errorf("minimum amount is above maximum amount")
+	}
+
+	selector := map[string]interface{}{
+		"txId": map[string]interface{}{"$gt": ""},
+	}
+	if filter.Type != "" {
+		selector["type"] = filter.Type
+	}
+	if filter.From != "" {
+		selector["from"] = filter.From
+	}
+	if filter.To != "" {
+		selector["to"] = filter.To
+	}
+	if filter.Category != "" {
+		selector["category"] = filter.Category
+	}
+	if filter.Reference != "" {
+		selector["reference"] = filter.Reference
+	}
+	switch filter.Asset {
+	case "":
+	case DefaultAsset:
+		// VAP records only name the asset when a closure sweeps it
+		selector["$or"] = []interface{}{
+			map[string]interface{}{"asset": map[string]interface{}{"$exists": false}},
+			map[string]interface{}{"asset": DefaultAsset},
+		}
+	default:
+		selector["asset"] = filter.Asset
+	}
+
+	timestamp := map[string]interface{}{"$gte": filter.StartTime}
+	if filter.EndTime != 0 {
+		timestamp["$lte"] = filter.EndTime
+	}
+	selector["timestamp"] = timestamp
+
+	if filter.MinAmount != 0 || filter.MaxAmount != 0 {
+		amount := map[string]interface{}{"$gte": filter.MinAmount}
+		if filter.MaxAmount != 0 {
+			amount["$lte"] = filter.MaxAmount
+		}
+		selector["amount"] = amount
+	}
+
+	query, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return "", err
+	}
+	return string(query), nil
+}
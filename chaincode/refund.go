@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Refund states recorded on the original payment
+const (
+	RefundStatusPartial = "partially_refunded"
+	RefundStatusFull    = "refunded"
+)
+
+// Refund returns part or all of a payment, a transfer or a captured hold, to
+// the payer. Only the original payee may refund, and cumulative refunds cannot exceed the original amount.
+func (s *SmartContract) Refund(ctx contractapi.TransactionContextInterface, originalTxID string, amount int64, reason string) (*TransactionRecord, error) {
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+	if len(reason) > maxReasonLength {
+		return nil, fmt.Errorf("reason must be at most %d characters", maxReasonLength)
+	}
+
+	original, err := getTransactionRecord(ctx, originalTxID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Type != "transfer" && original.Type != "capture" {
+		return nil, fmt.Errorf("only transfers and captures can be refunded, %s is a %s", originalTxID, original.Type)
+	}
+	if original.assetOf() != DefaultAsset {
+		return nil, fmt.Errorf("only %s transfers can be refunded, %s moved %s", DefaultAsset, originalTxID, original.Asset)
+	}
+	if _, err := requireWalletAccess(ctx, original.To); err != nil {
+		return nil, err
+	}
+
+	refunded, err := addAmount(original.RefundedAmount, amount)
+	if err != nil {
+		return nil, err
+	}
+	if refunded > original.Amount {
+		return nil, newContractError(ErrCodeRefundExceeded, "refund of %d exceeds the %d remaining on %s", amount, original.Amount-original.RefundedAmount, originalTxID)
+	}
+
+	// Refunds never collect a fee, and the fee on the original payment is not returned
+	record, _, err := transfer(ctx, original.To, original.From, amount, "refund")
+	if err != nil {
+		return nil, err
+	}
+	record.OriginalTxID = originalTxID
+	record.Reason = reason
+	if err := putTransactionRecord(ctx, record); err != nil {
+		return nil, err
+	}
+
+	original.RefundedAmount = refunded
+	original.RefundStatus = RefundStatusPartial
+	if refunded == original.Amount {
+		original.RefundStatus = RefundStatusFull
+	}
+	if err := putTransactionRecord(ctx, original); err != nil {
+		return nil, err
+	}
+
+	return record, emitEvent(ctx, EventRefund, LedgerEvent{Record: record})
+}
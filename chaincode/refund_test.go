@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRefundsCannotExceedThePayment(t *testing.T) {
+	l, s := newSeededLedger(t)
+	student := testIdentity{role: "student", wallet: "student1"}
+	merchant := testIdentity{role: "merchant", wallet: "merchant1"}
+	paymentID, err := s.Transfer(l.tx(student), "student1", "merchant1", 4000, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name       string
+		identity   testIdentity
+		amount     int64
+		wantCode   string
+		wantStatus string
+	}{
+		{"payer refunding itself", student, 1000, ErrCodeForbidden, ""},
+		{"partial", merchant, 1500, "", RefundStatusPartial},
+		{"more than remains", merchant, 3000, ErrCodeRefundExceeded, RefundStatusPartial},
+		{"the rest", merchant, 2500, "", RefundStatusFull},
+		{"after a full refund", merchant, 1, ErrCodeRefundExceeded, RefundStatusFull},
+	}
+	for _, step := range steps {
+		_, err := s.Refund(l.tx(step.identity), paymentID, step.amount, "returned")
+		var contractErr *ContractError
+		switch {
+		case step.wantCode == "" && err != nil:
+			t.Fatalf("%s: %v", step.name, err)
+		case step.wantCode != "" && (!errors.As(err, &contractErr) || contractErr.Code != step.wantCode):
+			t.Fatalf("%s: error = %v, want %s", step.name, err, step.wantCode)
+		}
+
+		original, err := s.GetTransaction(l.admin(), paymentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if original.RefundStatus != step.wantStatus {
+			t.Errorf("%s: refund status = %q, want %q", step.name, original.RefundStatus, step.wantStatus)
+		}
+	}
+
+	if student, merchant := l.wallet("student1").Balance, l.wallet("merchant1").Balance; student != 10000 || merchant != 0 {
+		t.Errorf("balances = %d and %d, want 10000 and 0", student, merchant)
+	}
+}
+
+func TestOnlyPaymentsCanBeRefunded(t *testing.T) {
+	l, s := newSeededLedger(t)
+	merchant := testIdentity{role: "merchant", wallet: "merchant1"}
+	paymentID, err := s.Transfer(l.tx(testIdentity{role: "student", wallet: "student1"}), "student1", "merchant1", 4000, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refund, err := s.Refund(l.tx(merchant), paymentID, 1000, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.OriginalTxID != paymentID || refund.Type != "refund" {
+		t.Errorf("refund = %+v, want a refund of %s", refund, paymentID)
+	}
+
+	if _, err := s.Refund(l.tx(testIdentity{role: "student", wallet: "student1"}), refund.TxID, 500, ""); err == nil {
+		t.Error("refunded a refund")
+	}
+	if _, err := s.Refund(l.tx(merchant), "missing", 500, ""); err == nil {
+		t.Error("refunded a missing transaction")
+	}
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	scheduleObjectType    = "schedule"
+	scheduleRunObjectType = "schedulerun"
+	// cohortWalletIndex lists the wallets of each cohort; putWallet keeps it in step
+	cohortWalletIndex = "cohort~wallet"
+	// maxScheduleRunsPerExecution bounds the periods paid by one ExecuteDueSchedules
+	// call; schedules still due are paid by the next call
+	maxScheduleRunsPerExecution = 50
+)
+
+// Schedule statuses
+const (
+	ScheduleStatusActive    = "active"
+	ScheduleStatusCancelled = "cancelled"
+)
+
+// scheduleCadences lists the supported schedule intervals
+var scheduleCadences = map[string]bool{
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+}
+
+// Schedule pays Amount from Source to each recipient once per period. Recipients
+// are either listed explicitly or are every active wallet that admins placed
+// in Cohort with SetWalletCohort, as of the time of the run.
+type Schedule struct {
+	ID         string   `json:"id"`
+	Source     string   `json:"source"`
+	Recipients []string `json:"recipients,omitempty"`
+	Cohort     string   `json:"cohort,omitempty"`
+	Amount     int64    `json:"amount"`  // minor units per recipient per period
+	Cadence    string   `json:"cadence"` // "daily", "weekly" or "monthly"
+	NextRun    int64    `json:"nextRun"` // start of the next unpaid period, Unix seconds
+	LastRun    int64    `json:"lastRun,omitempty"`
+	RunCount   int      `json:"runCount"`
+	Status     string   `json:"status"`
+	CreatedAt  int64    `json:"createdAt"`
+}
+
+// ScheduleRun records the payment of one schedule period. Runs are keyed by
+// schedule and period, so a period can only ever be paid once.
+type ScheduleRun struct {
+	ScheduleID string   `json:"scheduleId"`
+	Period     int64    `json:"period"` // the NextRun that was paid, Unix seconds
+	TxID       string   `json:"txId"`
+	ExecutedAt int64    `json:"executedAt"`
+	Paid       []string `json:"paid"`
+	Skipped    []string `json:"skipped,omitempty"` // recipients that were frozen or closed
+	Total      int64    `json:"total"`
+}
+
+// ScheduleFailure reports a due schedule that could not be paid; it stays due
+type ScheduleFailure struct {
+	ScheduleID string `json:"scheduleId"`
+	Period     int64  `json:"period"`
+	Error      string `json:"error"`
+}
+
+// ScheduleExecution is the outcome of ExecuteDueSchedules
+type ScheduleExecution struct {
+	Now      int64              `json:"now"`
+	Runs     []*ScheduleRun     `json:"runs"`
+	Failures []*ScheduleFailure `json:"failures"`
+	Pending  bool               `json:"pending"` // more periods are due than one call pays
+}
+
+// CreateSchedule registers a recurring payment from source. Exactly one of
+// recipients and cohort must be given. firstRun is a Unix timestamp in seconds. Admin only.
+func (s *SmartContract) CreateSchedule(ctx contractapi.TransactionContextInterface, id string, source string, recipients []string, cohort string, amount int64, cadence string, firstRun int64) (*Schedule, error) {
+	if _, err := requireAdmin(ctx, "CreateSchedule"); err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, fmt.Errorf("schedule id is required")
+	}
+	if err := validateText("schedule id", id, maxReferenceLength); err != nil {
+		return nil, err
+	}
+	if err := validateAmount(amount); err != nil {
+		return nil, err
+	}
+	if !scheduleCadences[cadence] {
+		return nil, fmt.Errorf("unknown cadence %q", cadence)
+	}
+	if firstRun <= 0 {
+		return nil, fmt.Errorf("first run must be a Unix timestamp")
+	}
+	if cadence == "monthly" && time.Unix(firstRun, 0).UTC().Day() > 28 {
+		return nil, fmt.Errorf("monthly schedules must start on day 1 to 28 so every month has a run")
+	}
+	if (len(recipients) == 0) == (cohort == "") {
+		return nil, fmt.Errorf("exactly one of recipients and cohort is required")
+	}
+	if len(recipients) > maxBatchSize {
+		return nil, fmt.Errorf("schedule has %d recipients, the maximum is %d", len(recipients), maxBatchSize)
+	}
+
+	existing, err := getSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("schedule %s already exists", id)
+	}
+	if _, err := getWallet(ctx, source); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, recipient := range recipients {
+		if recipient == source {
+			return nil, fmt.Errorf("schedule cannot pay its source wallet")
+		}
+		if seen[recipient] {
+			return nil, fmt.Errorf("recipient %s is listed twice", recipient)
+		}
+		seen[recipient] = true
+		if _, err := getWallet(ctx, recipient); err != nil {
+			return nil, err
+		}
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	schedule := &Schedule{
+		ID:         id,
+		Source:     source,
+		Recipients: recipients,
+		Cohort:     cohort,
+		Amount:     amount,
+		Cadence:    cadence,
+		NextRun:    firstRun,
+		Status:     ScheduleStatusActive,
+		CreatedAt:  timestamp.Seconds,
+	}
+	return schedule, putSchedule(ctx, schedule)
+}
+
+// SetWalletCohort places a wallet in the cohort that cohort schedules pay, such
+// as a year group; an empty cohort removes it from its cohort. Admin only.
+func (s *SmartContract) SetWalletCohort(ctx contractapi.TransactionContextInterface, walletID string, cohort string) error {
+	if _, err := requireAdmin(ctx, "SetWalletCohort"); err != nil {
+		return err
+	}
+	if err := validateText("cohort", cohort, maxReferenceLength); err != nil {
+		return err
+	}
+
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	if wallet.CurrentStatus() == WalletStatusClosed {
+		return newContractError(ErrCodeWalletClosed, "wallet %s is closed", walletID)
+	}
+
+	wallet.Cohort = cohort
+	return putWallet(ctx, wallet)
+}
+
+// CancelSchedule stops a schedule. Its runs are kept. Admin only.
+func (s *SmartContract) CancelSchedule(ctx contractapi.TransactionContextInterface, id string) (*Schedule, error) {
+	if _, err := requireAdmin(ctx, "CancelSchedule"); err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != ScheduleStatusActive {
+		return nil, fmt.Errorf("schedule %s is already %s", id, schedule.Status)
+	}
+
+	schedule.Status = ScheduleStatusCancelled
+	return schedule, putSchedule(ctx, schedule)
+}
+
+// GetSchedule returns a schedule by id
+func (s *SmartContract) GetSchedule(ctx contractapi.TransactionContextInterface, id string) (*Schedule, error) {
+	schedule, err := getSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, newContractError(ErrCodeNotFound, "schedule %s does not exist", id)
+	}
+	return schedule, nil
+}
+
+// GetAllSchedules returns every schedule, including cancelled ones
+func (s *SmartContract) GetAllSchedules(ctx contractapi.TransactionContextInterface) ([]*Schedule, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(scheduleObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	schedules := []*Schedule{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var schedule Schedule
+		err = json.Unmarshal(response.Value, &schedule)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, &schedule)
+	}
+
+	return schedules, nil
+}
+
+// GetScheduleRuns returns the paid periods of a schedule, oldest first
+func (s *SmartContract) GetScheduleRuns(ctx contractapi.TransactionContextInterface, id string) ([]*ScheduleRun, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(scheduleRunObjectType, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	runs := []*ScheduleRun{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var run ScheduleRun
+		err = json.Unmarshal(response.Value, &run)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, &run)
+	}
+
+	return runs, nil
+}
+
+// ExecuteDueSchedules pays every period of every active schedule that started
+// at or before now. Each schedule advances past a period only when it is paid,
+// and a period's run record is written in the same transaction, so calling
+// this again, or concurrently, never pays a period twice. A schedule whose
+// source cannot pay, or whose wallets cannot be read, is reported in Failures
+// and stays due. Admin only.
+func (s *SmartContract) ExecuteDueSchedules(ctx contractapi.TransactionContextInterface, now int64) (*ScheduleExecution, error) {
+	if _, err := requireAdmin(ctx, "ExecuteDueSchedules"); err != nil {
+		return nil, err
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if now > timestamp.Seconds {
+		return nil, fmt.Errorf("now is after the transaction timestamp")
+	}
+
+	schedules, err := s.GetAllSchedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	execution := &ScheduleExecution{Now: now, Runs: []*ScheduleRun{}, Failures: []*ScheduleFailure{}}
+	wallets := newWalletCache(ctx)
+	var records []*TransactionRecord
+	for _, schedule := range schedules {
+		for schedule.Status == ScheduleStatusActive && schedule.NextRun <= now {
+			if len(execution.Runs) == maxScheduleRunsPerExecution {
+				execution.Pending = true
+				break
+			}
+
+			run, recipients, err := planSchedulePeriod(ctx, wallets, schedule)
+			if err != nil {
+				execution.Failures = append(execution.Failures, &ScheduleFailure{ScheduleID: schedule.ID, Period: schedule.NextRun, Error: err.Error()})
+				break
+			}
+			if run != nil {
+				paid, err := paySchedulePeriod(ctx, wallets, schedule, run, recipients, len(records))
+				if err != nil {
+					return nil, err
+				}
+				records = append(records, paid...)
+				execution.Runs = append(execution.Runs, run)
+				schedule.LastRun = run.Period
+				schedule.RunCount++
+			}
+			schedule.NextRun = nextScheduleRun(schedule.NextRun, schedule.Cadence)
+			if err := putSchedule(ctx, schedule); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := wallets.putAll(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return execution, nil
+	}
+	return execution, emitEvent(ctx, EventSchedulesExecuted, LedgerEvent{Records: records})
+}
+
+// planSchedulePeriod checks that the schedule's current period can be paid and
+// returns its run and the recipients to pay, without changing any balance. It
+// returns a nil run when the period was already paid.
+func planSchedulePeriod(ctx contractapi.TransactionContextInterface, wallets *walletCache, schedule *Schedule) (*ScheduleRun, []*UserWallet, error) {
+	runKey, err := ctx.GetStub().CreateCompositeKey(scheduleRunObjectType, []string{schedule.ID, fmt.Sprintf("%020d", schedule.NextRun)})
+	if err != nil {
+		return nil, nil, err
+	}
+	existing, err := ctx.GetStub().GetState(runKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, nil, nil
+	}
+
+	source, err := wallets.get(schedule.Source)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := source.RequireActive(); err != nil {
+		return nil, nil, err
+	}
+
+	recipientIDs := schedule.Recipients
+	if schedule.Cohort != "" {
+		recipientIDs, err = getCohortWalletIDs(ctx, wallets, schedule.Cohort, schedule.Source)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	run := &ScheduleRun{ScheduleID: schedule.ID, Period: schedule.NextRun, TxID: ctx.GetStub().GetTxID(), Paid: []string{}}
+	var recipients []*UserWallet
+	for _, id := range recipientIDs {
+		recipient, err := wallets.get(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if recipient.RequireActive() != nil {
+			run.Skipped = append(run.Skipped, id)
+			continue
+		}
+		recipients = append(recipients, recipient)
+		run.Total, err = addAmount(run.Total, schedule.Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if source.Available() < run.Total {
+		return nil, nil, fmt.Errorf("insufficient funds: period total is %d, %s has %d available", run.Total, source.ID, source.Available())
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, nil, err
+	}
+	run.ExecutedAt = timestamp.Seconds
+	return run, recipients, nil
+}
+
+// paySchedulePeriod moves the funds of a planned run and stores its records
+// and run. offset numbers the records already written in this transaction.
+func paySchedulePeriod(ctx contractapi.TransactionContextInterface, wallets *walletCache, schedule *Schedule, run *ScheduleRun, recipients []*UserWallet, offset int) ([]*TransactionRecord, error) {
+	source, err := wallets.get(schedule.Source)
+	if err != nil {
+		return nil, err
+	}
+	runKey, err := ctx.GetStub().CreateCompositeKey(scheduleRunObjectType, []string{schedule.ID, fmt.Sprintf("%020d", run.Period)})
+	if err != nil {
+		return nil, err
+	}
+
+	source.debit(run.Total)
+	records := make([]*TransactionRecord, 0, len(recipients))
+	for _, recipient := range recipients {
+		recipient.Balance, err = addAmount(recipient.Balance, schedule.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := newTransactionRecord(ctx, source.ID, recipient.ID, schedule.Amount, "scheduled")
+		if err != nil {
+			return nil, err
+		}
+		record.TxID = fmt.Sprintf("%s_%d", run.TxID, offset+len(records)+1)
+		record.ScheduleID = schedule.ID
+		if err := putTransactionRecord(ctx, record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+		run.Paid = append(run.Paid, recipient.ID)
+	}
+
+	runJSON, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+	return records, ctx.GetStub().PutState(runKey, runJSON)
+}
+
+// getCohortWalletIDs returns the ids of the active wallets in a cohort, in key order
+func getCohortWalletIDs(ctx contractapi.TransactionContextInterface, wallets *walletCache, cohort string, exclude string) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(cohortWalletIndex, []string{cohort})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var ids []string
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 || compositeKeyParts[1] == exclude {
+			continue
+		}
+
+		wallet, err := wallets.get(compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		if wallet.RequireActive() == nil {
+			ids = append(ids, wallet.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// nextScheduleRun returns the start of the period after period
+func nextScheduleRun(period int64, cadence string) int64 {
+	t := time.Unix(period, 0).UTC()
+	switch cadence {
+	case "daily":
+		t = t.AddDate(0, 0, 1)
+	case "weekly":
+		t = t.AddDate(0, 0, 7)
+	default:
+		t = t.AddDate(0, 1, 0)
+	}
+	return t.Unix()
+}
+
+func getSchedule(ctx contractapi.TransactionContextInterface, id string) (*Schedule, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(scheduleObjectType, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	scheduleJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if scheduleJSON == nil {
+		return nil, nil
+	}
+
+	var schedule Schedule
+	err = json.Unmarshal(scheduleJSON, &schedule)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func putSchedule(ctx contractapi.TransactionContextInterface, schedule *Schedule) error {
+	key, err := ctx.GetStub().CreateCompositeKey(scheduleObjectType, []string{schedule.ID})
+	if err != nil {
+		return err
+	}
+	scheduleJSON, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, scheduleJSON)
+}
+
+// walletCache loads each wallet once per transaction, because GetState does
+// not see writes made earlier in the same transaction
+type walletCache struct {
+	ctx     contractapi.TransactionContextInterface
+	wallets map[string]*UserWallet
+	order   []string
+}
+
+func newWalletCache(ctx contractapi.TransactionContextInterface) *walletCache {
+	return &walletCache{ctx: ctx, wallets: make(map[string]*UserWallet)}
+}
+
+// get returns the loaded wallet, reading it and releasing its expired holds on first use
+func (c *walletCache) get(id string) (*UserWallet, error) {
+	if wallet, ok := c.wallets[id]; ok {
+		return wallet, nil
+	}
+	wallet, err := getWallet(c.ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := releaseExpiredHolds(c.ctx, wallet); err != nil {
+		return nil, err
+	}
+	c.wallets[id] = wallet
+	c.order = append(c.order, id)
+	return wallet, nil
+}
+
+// putAll writes every loaded wallet
+func (c *walletCache) putAll() error {
+	for _, id := range c.order {
+		if err := putWallet(c.ctx, c.wallets[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSchedulePaysEachPeriodOnce(t *testing.T) {
+	l, s := newSeededLedger(t)
+	start := l.now
+	if _, err := s.CreateSchedule(l.admin(), "allowance", "admin", []string{"student1"}, "", 500, "daily", start-2*86400); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name        string
+		offset      int64
+		wantRuns    int
+		wantBalance int64
+	}{
+		{"catches up on missed periods", 0, 3, 10000 + 1500},
+		{"repeated call", 0, 0, 10000 + 1500},
+		{"before the next period", 86399, 0, 10000 + 1500},
+		{"next period", 86400, 1, 10000 + 2000},
+		{"next period again", 86400, 0, 10000 + 2000},
+	}
+	for _, step := range steps {
+		l.now = start + step.offset
+		execution, err := s.ExecuteDueSchedules(l.admin(), l.now)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if len(execution.Runs) != step.wantRuns || len(execution.Failures) != 0 {
+			t.Errorf("%s: %d runs and failures %v, want %d runs", step.name, len(execution.Runs), execution.Failures, step.wantRuns)
+		}
+		if got := l.wallet("student1").Balance; got != step.wantBalance {
+			t.Errorf("%s: student1 balance = %d, want %d", step.name, got, step.wantBalance)
+		}
+	}
+
+	runs, err := s.GetScheduleRuns(l.admin(), "allowance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 4 || runs[0].Period != start-2*86400 || runs[3].Period != start+86400 {
+		t.Errorf("runs = %d, want the 4 periods from %d", len(runs), start-2*86400)
+	}
+}
+
+func TestScheduleSkipsAPeriodAlreadyRun(t *testing.T) {
+	l, s := newSeededLedger(t)
+	if _, err := s.CreateSchedule(l.admin(), "allowance", "admin", []string{"student1"}, "", 500, "daily", l.now); err != nil {
+		t.Fatal(err)
+	}
+	// A concurrent execution already paid this period
+	runKey, _ := l.stub.CreateCompositeKey(scheduleRunObjectType, []string{"allowance", fmt.Sprintf("%020d", l.now)})
+	l.put(runKey, fmt.Sprintf(`{"scheduleId":"allowance","period":%d}`, l.now))
+
+	execution, err := s.ExecuteDueSchedules(l.admin(), l.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(execution.Runs) != 0 {
+		t.Errorf("%d runs, want the paid period skipped", len(execution.Runs))
+	}
+	if got := l.wallet("student1").Balance; got != 10000 {
+		t.Errorf("student1 balance = %d, want 10000", got)
+	}
+	schedule, err := s.GetSchedule(l.admin(), "allowance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.NextRun != l.now+86400 || schedule.RunCount != 0 {
+		t.Errorf("schedule = %+v, want it advanced past the paid period", schedule)
+	}
+}
+
+func TestCohortSchedulePaysActiveMembers(t *testing.T) {
+	l, s := newSeededLedger(t)
+	for _, id := range []string{"student2", "student3"} {
+		if err := s.CreateWallet(l.admin(), id, "student"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"student1", "student2", "student3"} {
+		if err := s.SetWalletCohort(l.admin(), id, "2026"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetWalletCohort(l.admin(), "student3", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FreezeWallet(l.admin(), "student2", "other", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateSchedule(l.admin(), "stipend", "admin", nil, "2026", 500, "weekly", l.now); err != nil {
+		t.Fatal(err)
+	}
+
+	execution, err := s.ExecuteDueSchedules(l.admin(), l.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(execution.Runs) != 1 {
+		t.Fatalf("%d runs, want 1", len(execution.Runs))
+	}
+	run := execution.Runs[0]
+	if len(run.Paid) != 1 || run.Paid[0] != "student1" {
+		t.Errorf("run paid %v, want only student1", run.Paid)
+	}
+	for id, want := range map[string]int64{"student1": 10500, "student2": 0, "student3": 0} {
+		if got := l.wallet(id).Balance; got != want {
+			t.Errorf("%s balance = %d, want %d", id, got, want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -14,21 +16,110 @@ type SmartContract struct {
 
 // UserWallet describes the wallet structure
 type UserWallet struct {
-	ID      string  `json:"id"`
-	Balance float64 `json:"balance"`
-	Type    string  `json:"type"` // "student", "merchant", "admin"
+	ID      string `json:"id"`
+	Balance int64  `json:"balance"`        // total in minor units (paise), including held funds
+	Held    int64  `json:"held,omitempty"` // reserved by active holds, see holds.go
+	Type    string `json:"type"`           // "student", "merchant", "admin"
+	// Category selects a merchant's fee policy, see fees.go
+	Category string `json:"category,omitempty"`
+	// Cohort groups wallets for scheduled payouts, e.g. a year group, see schedule.go
+	Cohort string `json:"cohort,omitempty"`
+
+	// Public profile shown to payers, see wallet_metadata.go
+	DisplayName       string `json:"displayName,omitempty"`
+	Location          string `json:"location,omitempty"`   // merchants only
+	LogoRef           string `json:"logoRef,omitempty"`    // merchant logo URL or content hash
+	Department        string `json:"department,omitempty"` // students only, their cohort or department
+	MetadataUpdatedAt int64  `json:"metadataUpdatedAt,omitempty"`
+
+	// Status is "active", "frozen" or "closed". Wallets written before
+	// statuses existed have none and are treated as active.
+	Status          string `json:"status,omitempty"`
+	StatusReason    string `json:"statusReason,omitempty"` // reason code, see wallet_status.go
+	StatusNote      string `json:"statusNote,omitempty"`
+	StatusChangedBy string `json:"statusChangedBy,omitempty"`
+	StatusChangedAt int64  `json:"statusChangedAt,omitempty"`
+
+	// DetailsHash is the SHA-256 of the salted owner details in the private
+	// walletDetailsCollection, see private_details.go
+	DetailsHash string `json:"detailsHash,omitempty"`
+
+	// Grants are the unspent expiring lots included in Balance, oldest first, see grants.go
+	Grants []*GrantLot `json:"grants,omitempty"`
+
+	// asOf is the transaction time the wallet was read at, which decides the expired grants
+	asOf int64
+	// indexKeys are the composite keys that indexed the wallet when it was read
+	indexKeys []string
 }
 
 // TransactionRecord describes a transaction
 type TransactionRecord struct {
+	TxID       string `json:"txId"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Amount     int64  `json:"amount"` // minor units (paise)
+	Timestamp  int64  `json:"timestamp"`
+	Type       string `json:"type"`                 // "mint", "transfer", "burn", "refund", "capture", "fee", "scheduled", "grant", "expiry", "closure"
+	Reason     string `json:"reason,omitempty"`     // why coins were burned or refunded
+	RequestID  string `json:"requestId,omitempty"`  // payment request settled by this transfer
+	HoldID     string `json:"holdId,omitempty"`     // hold settled by this capture
+	BatchID    string `json:"batchId,omitempty"`    // Fabric transaction of a batch line, see batch.go
+	ScheduleID string `json:"scheduleId,omitempty"` // recurring schedule that paid this record, see schedule.go
+	ExpiresAt  int64  `json:"expiresAt,omitempty"`  // when the lot of a "grant" expires
+	Asset      string `json:"asset,omitempty"`      // registered asset moved, empty for VAP, see assets.go
+	GrantTxID  string `json:"grantTxId,omitempty"`  // grant whose lot an "expiry" returned
+	Spender    string `json:"spender,omitempty"`    // delegate who sent a TransferFrom on the owner's behalf
+
+	// Optional payment details, see memo.go
+	Memo      string `json:"memo,omitempty"` // only on records from before memos were made private
+	Category  string `json:"category,omitempty"`
+	Reference string `json:"reference,omitempty"` // external reference, indexed under ref~tx
+	// DetailsHash is the SHA-256 of the salted details kept in the private transferDetailsCollection
+	DetailsHash string `json:"detailsHash,omitempty"`
+
+	// Merchant payments record the fee withheld; the fee line item links back to the payment
+	Fee         int64  `json:"fee,omitempty"`
+	PaymentTxID string `json:"paymentTxId,omitempty"`
+
+	// Refunds reference the payment they reverse; the payment tracks the total refunded
+	OriginalTxID   string `json:"originalTxId,omitempty"`
+	RefundedAmount int64  `json:"refundedAmount,omitempty"`
+	RefundStatus   string `json:"refundStatus,omitempty"` // "partially_refunded", "refunded"
+}
+
+// legacyUserWallet and legacyTransactionRecord are the float64 layouts
+// written before amounts moved to minor units. Wallet history decodes old
+// snapshots with the former; ReindexHistory reads records with the latter so
+// that both layouts decode.
+type legacyUserWallet struct {
+	ID      string  `json:"id"`
+	Balance float64 `json:"balance"`
+	Type    string  `json:"type"`
+}
+
+type legacyTransactionRecord struct {
 	TxID      string  `json:"txId"`
 	From      string  `json:"from"`
 	To        string  `json:"to"`
 	Amount    float64 `json:"amount"`
 	Timestamp int64   `json:"timestamp"`
-	Type      string  `json:"type"` // "mint", "transfer"
+	Type      string  `json:"type"`
 }
 
+// schemaVersionKey marks ledgers whose amounts are stored in minor units
+const (
+	schemaVersionKey     = "SCHEMA_VERSION"
+	currentSchemaVersion = "2"
+)
+
+// legacyWalletFields and legacyRecordFields are every field the float64
+// layouts had. MigrateToMinorUnits only converts values with no other fields.
+var (
+	legacyWalletFields = map[string]bool{"id": true, "balance": true, "type": true}
+	legacyRecordFields = map[string]bool{"txId": true, "from": true, "to": true, "amount": true, "timestamp": true, "type": true}
+)
+
 // PaginatedResponse describes the response for paginated transactions
 type PaginatedResponse struct {
 	Records      []*TransactionRecord `json:"records"`
@@ -38,10 +129,35 @@ type PaginatedResponse struct {
 
 // InitLedger adds a base set of wallets to the ledger
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	if _, err := requireAdmin(ctx, "InitLedger"); err != nil {
+		return err
+	}
+
 	wallets := []UserWallet{
-		{ID: "admin", Balance: 1000000, Type: "admin"},
-		{ID: "student1", Balance: 100, Type: "student"},
-		{ID: "merchant1", Balance: 0, Type: "merchant"},
+		{ID: "admin", Balance: 1000000 * AmountScale, Type: "admin", Status: WalletStatusActive},
+		{ID: "student1", Balance: 100 * AmountScale, Type: "student", Status: WalletStatusActive},
+		{ID: "merchant1", Balance: 0, Type: "merchant", Status: WalletStatusActive},
+	}
+
+	// Only an empty ledger starts out in minor units; an unmarked one with
+	// wallets still holds float amounts and must be migrated first
+	version, err := ctx.GetStub().GetState(schemaVersionKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if version == nil {
+		resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+		if err != nil {
+			return err
+		}
+		empty := !resultsIterator.HasNext()
+		resultsIterator.Close()
+		if !empty {
+			return fmt.Errorf("ledger still stores float amounts, run MigrateToMinorUnits first")
+		}
+		if err := ctx.GetStub().PutState(schemaVersionKey, []byte(currentSchemaVersion)); err != nil {
+			return fmt.Errorf("failed to put to world state. %v", err)
+		}
 	}
 
 	for _, wallet := range wallets {
@@ -72,6 +188,10 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 // ReindexHistory creates the composite keys for existing transactions
 // This is useful when upgrading from a version without pagination/indexing
 func (s *SmartContract) ReindexHistory(ctx contractapi.TransactionContextInterface) error {
+	if _, err := requireAdmin(ctx, "ReindexHistory"); err != nil {
+		return err
+	}
+
 	// Iterate over all transactions
 	resultsIterator, err := ctx.GetStub().GetStateByRange("TX_", "TX_\uffff")
 	if err != nil {
@@ -85,198 +205,435 @@ func (s *SmartContract) ReindexHistory(ctx contractapi.TransactionContextInterfa
 			return err
 		}
 
-		var record TransactionRecord
+		// Records may not be migrated yet, and float amounts do not decode into TransactionRecord
+		var record legacyTransactionRecord
 		err = json.Unmarshal(queryResponse.Value, &record)
 		if err != nil {
-			continue
+			return fmt.Errorf("failed to decode transaction %s: %v", queryResponse.Key, err)
 		}
 
 		// Create composite keys
 		indexName := "user~tx"
-
-		// Sender Index
-		if record.From != "system" { // Don't index system mints for sender if not needed, or do it.
-			senderKey, err := ctx.GetStub().CreateCompositeKey(indexName, []string{record.From, record.TxID})
-			if err == nil {
-				ctx.GetStub().PutState(senderKey, []byte{0x00})
+		for _, party := range []string{record.From, record.To} {
+			if party == "system" {
+				continue
+			}
+			key, err := ctx.GetStub().CreateCompositeKey(indexName, []string{party, record.TxID})
+			if err != nil {
+				return err
+			}
+			if err := ctx.GetStub().PutState(key, []byte{0x00}); err != nil {
+				return fmt.Errorf("failed to put to world state. %v", err)
 			}
-		}
-
-		// Receiver Index
-		receiverKey, err := ctx.GetStub().CreateCompositeKey(indexName, []string{record.To, record.TxID})
-		if err == nil {
-			ctx.GetStub().PutState(receiverKey, []byte{0x00})
 		}
 	}
 
 	return nil
 }
 
-// Mint creates new coins and adds them to the admin wallet
-func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount float64) error {
-	// In a real scenario, we would check the client's identity to ensure they are an admin.
-	// For this MVP, we will assume the caller is authorized or check the ID passed.
-	// However, Fabric CA identity check is better.
-	// For simplicity in MVP, we'll just add to the 'admin' wallet.
+// MigrateToMinorUnits converts wallets and transaction records written with
+// float64 amounts to int64 minor units. It can only run once per ledger.
+// Values are recognized as legacy by their shape: anything with a field the
+// float64 layouts did not have was written by this version and is left
+// alone. Only the amount is rewritten, every other field is kept as stored.
+// Functions that move funds refuse to run until the migration is done, see
+// requireMinorUnits, so no record in the new layout exists before it.
+func (s *SmartContract) MigrateToMinorUnits(ctx contractapi.TransactionContextInterface) error {
+	if _, err := requireAdmin(ctx, "MigrateToMinorUnits"); err != nil {
+		return err
+	}
 
-	walletJSON, err := ctx.GetStub().GetState("admin")
+	version, err := ctx.GetStub().GetState(schemaVersionKey)
 	if err != nil {
 		return fmt.Errorf("failed to read from world state: %v", err)
 	}
-	if walletJSON == nil {
-		return fmt.Errorf("the wallet %s does not exist", "admin")
+	if version != nil {
+		return fmt.Errorf("ledger is already at schema version %s", string(version))
 	}
 
-	var wallet UserWallet
-	err = json.Unmarshal(walletJSON, &wallet)
+	// An empty range covers every simple key: wallets, TX_ records and the schema marker
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
 	if err != nil {
 		return err
 	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
 
-	wallet.Balance += amount
+		if queryResponse.Key == supplyKey {
+			continue
+		}
 
-	walletJSON, err = json.Marshal(wallet)
-	if err != nil {
-		return err
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(queryResponse.Value, &fields); err != nil {
+			return fmt.Errorf("failed to decode %s: %v", queryResponse.Key, err)
+		}
+		amountField, legacyFields := "balance", legacyWalletFields
+		if strings.HasPrefix(queryResponse.Key, "TX_") {
+			amountField, legacyFields = "amount", legacyRecordFields
+		}
+		if !hasLegacyShape(fields, amountField, legacyFields) {
+			continue
+		}
+
+		var legacyAmount float64
+		if err := json.Unmarshal(fields[amountField], &legacyAmount); err != nil {
+			return fmt.Errorf("failed to decode %s of %s: %v", amountField, queryResponse.Key, err)
+		}
+		amount, err := legacyToMinorUnits(legacyAmount)
+		if err != nil {
+			return fmt.Errorf("%s: %v", queryResponse.Key, err)
+		}
+		fields[amountField], err = json.Marshal(amount)
+		if err != nil {
+			return err
+		}
+
+		migratedJSON, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PutState(queryResponse.Key, migratedJSON)
+		if err != nil {
+			return fmt.Errorf("failed to put to world state. %v", err)
+		}
 	}
 
-	err = ctx.GetStub().PutState("admin", walletJSON)
-	if err != nil {
-		return err
+	return ctx.GetStub().PutState(schemaVersionKey, []byte(currentSchemaVersion))
+}
+
+// hasLegacyShape reports whether a stored value has the amount field and no
+// field outside the float64 layout
+func hasLegacyShape(fields map[string]json.RawMessage, amountField string, legacyFields map[string]bool) bool {
+	if _, ok := fields[amountField]; !ok {
+		return false
+	}
+	for name := range fields {
+		if !legacyFields[name] {
+			return false
+		}
 	}
+	return true
+}
 
-	// Record Transaction History for Mint
-	txID := ctx.GetStub().GetTxID()
-	timestamp, _ := ctx.GetStub().GetTxTimestamp()
+// requireMinorUnits refuses to write balances or records to a ledger that
+// MigrateToMinorUnits has not converted yet. Its float amounts would be read
+// as minor units, and new values would be scaled again by the migration.
+func requireMinorUnits(ctx contractapi.TransactionContextInterface) error {
+	version, err := ctx.GetStub().GetState(schemaVersionKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if version == nil {
+		return fmt.Errorf("ledger still stores float amounts, run MigrateToMinorUnits first")
+	}
+	return nil
+}
 
-	record := TransactionRecord{
-		TxID:      txID,
-		From:      "system",
-		To:        "admin",
-		Amount:    amount,
-		Timestamp: timestamp.Seconds,
-		Type:      "mint",
+// Mint executes an approved mint proposal, see mint_governance.go. VAP is
+// credited to the admin wallet and a registered asset to its issuer. It
+// returns the transaction ID, or that of the original execution when
+// idempotencyKey was already used, see idempotency.go.
+func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, proposalID string, idempotencyKey string) (string, error) {
+	if _, err := requireAdmin(ctx, "Mint"); err != nil {
+		return "", err
+	}
+	originalTxID, err := claimIdempotencyKey(ctx, "system", idempotencyKey, "Mint", proposalID)
+	if err != nil || originalTxID != "" {
+		return originalTxID, err
 	}
 
-	recordJSON, _ := json.Marshal(record)
+	// Without a registry nobody has approved anything, whatever the proposal says
+	governance, err := getMintGovernance(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(governance.Approvers) == 0 {
+		return "", newContractError(ErrCodeForbidden, "no mint approvers are registered, see SetMintApprovers")
+	}
+	proposal, err := getOpenProposal(ctx, proposalID)
+	if err != nil {
+		return "", err
+	}
+	if proposal.Kind != ProposalKindMint {
+		return "", fmt.Errorf("proposal %s is not a mint proposal", proposalID)
+	}
+	if proposal.Status != ProposalStatusApproved {
+		return "", newContractError(ErrCodeForbidden, "proposal %s has %d of the %d approvals required", proposalID, governance.countApprovals(proposal), governance.Threshold)
+	}
 
-	// Indexing for pagination/search by user
-	indexName := "user~tx"
-	receiverKey, err := ctx.GetStub().CreateCompositeKey(indexName, []string{"admin", txID})
+	token, err := resolveAsset(ctx, proposal.Asset)
 	if err != nil {
-		return err
+		return "", err
+	}
+	var record *TransactionRecord
+	if token.Symbol != DefaultAsset {
+		record, err = mintAsset(ctx, token, proposal.Amount)
+	} else {
+		record, err = mintVAP(ctx, proposal.Amount)
 	}
-	err = ctx.GetStub().PutState(receiverKey, []byte{0x00})
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return ctx.GetStub().PutState("TX_"+txID, recordJSON)
+	proposal.Status = ProposalStatusExecuted
+	proposal.ExecutedTxID = record.TxID
+	proposal.ClosedAt = record.Timestamp
+	if err := putProposal(ctx, proposal); err != nil {
+		return "", err
+	}
+
+	return record.TxID, emitEvent(ctx, EventMint, LedgerEvent{Record: record, Proposal: proposal})
 }
 
-// Transfer moves coins from one wallet to another
-func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fromID string, toID string, amount float64) error {
-	if amount <= 0 {
-		return fmt.Errorf("transfer amount must be positive")
+// mintVAP creates new VAP in the admin wallet and stores the mint record
+func mintVAP(ctx contractapi.TransactionContextInterface, amount int64) (*TransactionRecord, error) {
+	if err := validateAmount(amount); err != nil {
+		return nil, err
 	}
 
-	// Get Sender
-	fromWalletJSON, err := ctx.GetStub().GetState(fromID)
+	// Track circulation before touching balances
+	if err := adjustSupply(ctx, amount); err != nil {
+		return nil, err
+	}
+
+	wallet, err := getWallet(ctx, "admin")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return nil, err
 	}
-	if fromWalletJSON == nil {
-		return fmt.Errorf("sender wallet %s does not exist", fromID)
+	if err := wallet.RequireActive(); err != nil {
+		return nil, err
 	}
 
-	var fromWallet UserWallet
-	err = json.Unmarshal(fromWalletJSON, &fromWallet)
+	wallet.Balance, err = addAmount(wallet.Balance, amount)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if fromWallet.Balance < amount {
-		return fmt.Errorf("insufficient funds")
+	err = putWallet(ctx, wallet)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get Receiver
-	toWalletJSON, err := ctx.GetStub().GetState(toID)
+	// Record Transaction History for Mint
+	record, err := newTransactionRecord(ctx, "system", "admin", amount, "mint")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return nil, err
+	}
+	return record, putTransactionRecord(ctx, record)
+}
+
+// Transfer moves coins from one wallet to another. category and reference
+// are optional and may be empty. An empty asset means VAP. A memo and note
+// can only be passed under the "transferDetails" transient key, with a
+// "salt"; they are kept in a private collection, with only their salted hash
+// on the record.
+// It returns the transaction ID, or that of the original transfer when
+// idempotencyKey was already used by the sender, see idempotency.go.
+func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fromID string, toID string, amount int64, category string, reference string, asset string, idempotencyKey string) (string, error) {
+	if _, err := requireWalletAccess(ctx, fromID); err != nil {
+		return "", err
+	}
+	originalTxID, err := claimIdempotencyKey(ctx, fromID, idempotencyKey, "Transfer", fromID, toID, strconv.FormatInt(amount, 10), category, reference, asset)
+	if err != nil || originalTxID != "" {
+		return originalTxID, err
+	}
+	details := TransferDetails{Category: category, Reference: reference}
+	if err := details.validate(); err != nil {
+		return "", err
+	}
+	private, err := readTransferDetails(ctx)
+	if err != nil {
+		return "", err
 	}
-	if toWalletJSON == nil {
-		return fmt.Errorf("receiver wallet %s does not exist", toID)
+	token, err := resolveAsset(ctx, asset)
+	if err != nil {
+		return "", err
 	}
 
-	var toWallet UserWallet
-	err = json.Unmarshal(toWalletJSON, &toWallet)
+	var record, fee *TransactionRecord
+	if token.Symbol != DefaultAsset {
+		record, err = transferAsset(ctx, token, fromID, toID, amount)
+	} else {
+		record, fee, err = transfer(ctx, fromID, toID, amount, "transfer")
+	}
 	if err != nil {
-		return err
+		return "", err
+	}
+	details.apply(record)
+	if err := putTransferDetails(ctx, record, private); err != nil {
+		return "", err
+	}
+	if err := putTransactionRecord(ctx, record); err != nil {
+		return "", err
 	}
 
-	// Perform Transfer
-	fromWallet.Balance -= amount
-	toWallet.Balance += amount
+	return record.TxID, emitEvent(ctx, EventTransfer, LedgerEvent{Record: record, Fee: fee})
+}
 
-	// Update State
-	fromWalletJSON, _ = json.Marshal(fromWallet)
-	toWalletJSON, _ = json.Marshal(toWallet)
+// transfer moves funds between two wallets. It returns the record for the
+// caller to complete and store, and the stored fee line item if a merchant fee applied.
+func transfer(ctx contractapi.TransactionContextInterface, fromID string, toID string, amount int64, txType string) (*TransactionRecord, *TransactionRecord, error) {
+	if fromID == toID {
+		return nil, nil, fmt.Errorf("cannot transfer to the same wallet")
+	}
 
-	err = ctx.GetStub().PutState(fromID, fromWalletJSON)
+	// Get Sender
+	fromWallet, err := getWallet(ctx, fromID)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	err = ctx.GetStub().PutState(toID, toWalletJSON)
+
+	// Get Receiver
+	toWallet, err := getWallet(ctx, toID)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	// Record Transaction History
-	txID := ctx.GetStub().GetTxID()
-	timestamp, _ := ctx.GetStub().GetTxTimestamp()
+	return moveFunds(ctx, fromWallet, toWallet, amount, txType)
+}
 
-	record := TransactionRecord{
-		TxID:      txID,
-		From:      fromID,
-		To:        toID,
-		Amount:    amount,
-		Timestamp: timestamp.Seconds,
-		Type:      "transfer",
+// moveFunds debits and credits two loaded wallets, which must both be active.
+// Held funds cannot be spent, and spending limits apply to ordinary
+// "transfer" payments only, not to refunds and other settlement types.
+// Payments and captures to merchants also collect the merchant fee; the fee
+// line item is stored and returned alongside the payment record.
+func moveFunds(ctx contractapi.TransactionContextInterface, fromWallet *UserWallet, toWallet *UserWallet, amount int64, txType string) (*TransactionRecord, *TransactionRecord, error) {
+	if err := validateAmount(amount); err != nil {
+		return nil, nil, err
+	}
+	if err := fromWallet.RequireActive(); err != nil {
+		return nil, nil, err
+	}
+	if err := toWallet.RequireActive(); err != nil {
+		return nil, nil, err
 	}
 
-	recordJSON, _ := json.Marshal(record)
-	// We use a composite key to store history easily queryable if needed,
-	// or just rely on Fabric's built-in history for the key.
-	// For MVP, let's just emit an event or rely on GetHistoryForKey.
-	// But storing a record is good for the "Block Explorer" if we query by range.
+	if err := releaseExpiredHolds(ctx, fromWallet); err != nil {
+		return nil, nil, err
+	}
+	if fromWallet.Available() < amount {
+		return nil, nil, fmt.Errorf("insufficient funds")
+	}
 
-	// Indexing for pagination/search by user
-	indexName := "user~tx"
-	senderKey, err := ctx.GetStub().CreateCompositeKey(indexName, []string{fromID, txID})
+	if txType == "transfer" || txType == "capture" {
+		if err := checkTransferPolicy(ctx, fromWallet, toWallet, DefaultAsset, amount); err != nil {
+			return nil, nil, err
+		}
+	}
+	if txType == "transfer" {
+		if err := consumeSpendingAllowance(ctx, fromWallet, DefaultAsset, amount); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Perform Transfer
+	var err error
+	fromWallet.debit(amount)
+	toWallet.Balance, err = addAmount(toWallet.Balance, amount)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	err = ctx.GetStub().PutState(senderKey, []byte{0x00})
+
+	// Update State
+	err = putWallet(ctx, fromWallet)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	err = putWallet(ctx, toWallet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Record Transaction History
+	record, err := newTransactionRecord(ctx, fromWallet.ID, toWallet.ID, amount, txType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fee *TransactionRecord
+	if txType == "transfer" || txType == "capture" {
+		fee, err = collectMerchantFee(ctx, fromWallet, toWallet, record)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+	return record, fee, nil
+}
 
-	receiverKey, err := ctx.GetStub().CreateCompositeKey(indexName, []string{toID, txID})
+// newTransactionRecord builds a record for the current transaction
+func newTransactionRecord(ctx contractapi.TransactionContextInterface, from string, to string, amount int64, txType string) (*TransactionRecord, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
+		return nil, err
+	}
+
+	return &TransactionRecord{
+		TxID:      ctx.GetStub().GetTxID(),
+		From:      from,
+		To:        to,
+		Amount:    amount,
+		Timestamp: timestamp.Seconds,
+		Type:      txType,
+	}, nil
+}
+
+// putTransactionRecord stores the record under "TX_" + TxID and indexes it
+// for both parties under the user~tx composite key. The "system" party is not
+// indexed. Records with an external reference are also indexed under ref~tx.
+func putTransactionRecord(ctx contractapi.TransactionContextInterface, record *TransactionRecord) error {
+	if err := requireMinorUnits(ctx); err != nil {
 		return err
 	}
-	err = ctx.GetStub().PutState(receiverKey, []byte{0x00})
+	recordJSON, err := json.Marshal(record)
 	if err != nil {
 		return err
 	}
 
-	// Let's create a composite key for the transaction to store it as a separate state object
-	// Key: "TX" + TxID
-	return ctx.GetStub().PutState("TX_"+txID, recordJSON)
+	if record.Reference != "" {
+		key, err := ctx.GetStub().CreateCompositeKey(referenceTxIndex, []string{record.Reference, record.TxID})
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PutState(key, []byte{0x00})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Indexing for pagination/search by user
+	indexName := "user~tx"
+	for _, party := range []string{record.From, record.To} {
+		if party == "system" {
+			continue
+		}
+		key, err := ctx.GetStub().CreateCompositeKey(indexName, []string{party, record.TxID})
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PutState(key, []byte{0x00})
+		if err != nil {
+			return err
+		}
+	}
+
+	return ctx.GetStub().PutState("TX_"+record.TxID, recordJSON)
 }
 
 // CreateWallet initializes a new wallet for a user
 func (s *SmartContract) CreateWallet(ctx contractapi.TransactionContextInterface, id string, role string) error {
+	if _, err := requireAdmin(ctx, "CreateWallet"); err != nil {
+		return err
+	}
+	// Wallets share the key space with transaction records and ledger metadata
+	if id == "" || id == "system" || isReservedKey(id) {
+		return fmt.Errorf("invalid wallet id %q", id)
+	}
+
 	// Check if wallet already exists
 	walletJSON, err := ctx.GetStub().GetState(id)
 	if err != nil {
@@ -291,6 +648,7 @@ func (s *SmartContract) CreateWallet(ctx contractapi.TransactionContextInterface
 		ID:      id,
 		Balance: 0,
 		Type:    role,
+		Status:  WalletStatusActive,
 	}
 
 	walletJSON, err = json.Marshal(wallet)
@@ -298,13 +656,24 @@ func (s *SmartContract) CreateWallet(ctx contractapi.TransactionContextInterface
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, walletJSON)
+	err = ctx.GetStub().PutState(id, walletJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventWalletCreated, LedgerEvent{Wallet: &wallet})
 }
 
 // GetPaginatedTransactions returns transactions with pagination
 // If userId is provided, returns transactions for that user.
 // If userId is empty, returns all transactions.
-func (s *SmartContract) GetPaginatedTransactions(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string, userId string) (*PaginatedResponse, error) {
+// Only records of asset are returned, VAP when it is empty, so pages may be short.
+func (s *SmartContract) GetPaginatedTransactions(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string, userId string, asset string) (*PaginatedResponse, error) {
+	token, err := resolveAsset(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
 	var records []*TransactionRecord
 	var fetchedBookmark string
 
@@ -346,6 +715,9 @@ func (s *SmartContract) GetPaginatedTransactions(ctx contractapi.TransactionCont
 				if err != nil {
 					continue
 				}
+				if record.assetOf() != token.Symbol {
+					continue
+				}
 				records = append(records, &record)
 			}
 		}
@@ -370,6 +742,9 @@ func (s *SmartContract) GetPaginatedTransactions(ctx contractapi.TransactionCont
 			if err != nil {
 				return nil, err
 			}
+			if record.assetOf() != token.Symbol {
+				continue
+			}
 			records = append(records, &record)
 		}
 	}
@@ -407,14 +782,19 @@ func (s *SmartContract) GetAllTransactions(ctx contractapi.TransactionContextInt
 	return records, nil
 }
 
-// GetTransaction returns a specific transaction by ID
+// GetTransaction returns a specific transaction by ID, including the refund
+// status of payments
 func (s *SmartContract) GetTransaction(ctx contractapi.TransactionContextInterface, txID string) (*TransactionRecord, error) {
+	return getTransactionRecord(ctx, txID)
+}
+
+func getTransactionRecord(ctx contractapi.TransactionContextInterface, txID string) (*TransactionRecord, error) {
 	recordJSON, err := ctx.GetStub().GetState("TX_" + txID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
 	if recordJSON == nil {
-		return nil, fmt.Errorf("transaction %s does not exist", txID)
+		return nil, newContractError(ErrCodeNotFound, "transaction %s does not exist", txID)
 	}
 
 	var record TransactionRecord
@@ -426,8 +806,23 @@ func (s *SmartContract) GetTransaction(ctx contractapi.TransactionContextInterfa
 	return &record, nil
 }
 
-// GetBalance returns the balance of a wallet
-func (s *SmartContract) GetBalance(ctx contractapi.TransactionContextInterface, id string) (float64, error) {
+// GetBalance returns the balance of a wallet in minor units. An empty asset means VAP.
+func (s *SmartContract) GetBalance(ctx contractapi.TransactionContextInterface, id string, asset string) (int64, error) {
+	token, err := resolveAsset(ctx, asset)
+	if err != nil {
+		return 0, err
+	}
+	if token.Symbol != DefaultAsset {
+		if _, err := getWallet(ctx, id); err != nil {
+			return 0, err
+		}
+		balance, err := getAssetBalance(ctx, id, token.Symbol)
+		if err != nil {
+			return 0, err
+		}
+		return balance.Balance, nil
+	}
+
 	walletJSON, err := ctx.GetStub().GetState(id)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read from world state: %v", err)
@@ -445,32 +840,6 @@ func (s *SmartContract) GetBalance(ctx contractapi.TransactionContextInterface,
 	return wallet.Balance, nil
 }
 
-// GetHistory returns the transaction history for a specific asset (wallet)
-// Note: This uses Fabric's history query which returns modifications to a key
-func (s *SmartContract) GetHistory(ctx contractapi.TransactionContextInterface, id string) ([]string, error) {
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
-	if err != nil {
-		return nil, err
-	}
-	defer resultsIterator.Close()
-
-	var history []string
-	for resultsIterator.HasNext() {
-		response, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		// response.Value is the value of the key at that point in time
-		// response.TxId is the transaction ID
-		// response.Timestamp is the timestamp
-
-		history = append(history, fmt.Sprintf("TxID: %s, Value: %s", response.TxId, string(response.Value)))
-	}
-
-	return history, nil
-}
-
 func main() {
 	chaincode, err := contractapi.NewChaincode(&SmartContract{})
 	if err != nil {
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const supplyKey = "SUPPLY"
+
+// Supply tracks the coins in circulation, in minor units
+type Supply struct {
+	Minted int64 `json:"minted"`
+	Burned int64 `json:"burned"`
+	Total  int64 `json:"total"`
+}
+
+// getSupply reads the SUPPLY object. Ledgers created before supply tracking
+// are bootstrapped by treating the current sum of balances as minted.
+func getSupply(ctx contractapi.TransactionContextInterface) (*Supply, error) {
+	supplyJSON, err := ctx.GetStub().GetState(supplyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	var supply Supply
+	if supplyJSON != nil {
+		err = json.Unmarshal(supplyJSON, &supply)
+		if err != nil {
+			return nil, err
+		}
+		return &supply, nil
+	}
+
+	err = forEachWallet(ctx, func(wallet *UserWallet) error {
+		supply.Minted, err = addAmount(supply.Minted, wallet.Balance)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	supply.Total = supply.Minted
+
+	return &supply, nil
+}
+
+func putSupply(ctx contractapi.TransactionContextInterface, supply *Supply) error {
+	supplyJSON, err := json.Marshal(supply)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(supplyKey, supplyJSON)
+}
+
+// adjustSupply records minted (positive) or burned (negative) coins
+func adjustSupply(ctx contractapi.TransactionContextInterface, delta int64) error {
+	supply, err := getSupply(ctx)
+	if err != nil {
+		return err
+	}
+
+	if delta >= 0 {
+		supply.Minted, err = addAmount(supply.Minted, delta)
+	} else {
+		supply.Burned, err = addAmount(supply.Burned, -delta)
+	}
+	if err != nil {
+		return err
+	}
+	supply.Total = supply.Minted - supply.Burned
+
+	return putSupply(ctx, supply)
+}
+
+// Discrepancy kinds reported by VerifyLedgerInvariants
+const (
+	DiscrepancyNegativeBalance    = "negative_balance"
+	DiscrepancyInvalidHeld        = "invalid_held"
+	DiscrepancyInvalidGrants      = "invalid_grants"
+	DiscrepancySupplyMismatch     = "supply_mismatch"
+	DiscrepancySupplyInconsistent = "supply_inconsistent"
+	DiscrepancyMintedMismatch     = "minted_mismatch"
+	DiscrepancyBurnedMismatch     = "burned_mismatch"
+	DiscrepancyInvalidRecord      = "invalid_record"
+)
+
+// Discrepancy is one failed invariant. Key names the wallet, record or
+// ledger object involved; Expected and Actual are in minor units.
+type Discrepancy struct {
+	Type     string `json:"type"`
+	Key      string `json:"key"`
+	Expected int64  `json:"expected"`
+	Actual   int64  `json:"actual"`
+	Message  string `json:"message"`
+}
+
+// LedgerReport is the result of VerifyLedgerInvariants
+type LedgerReport struct {
+	CheckedAt       int64          `json:"checkedAt"`
+	Supply          Supply         `json:"supply"`
+	WalletCount     int            `json:"walletCount"`
+	WalletTotal     int64          `json:"walletTotal"`
+	RecordCount     int            `json:"recordCount"`
+	MintedInRecords int64          `json:"mintedInRecords"`
+	BurnedInRecords int64          `json:"burnedInRecords"`
+	Consistent      bool           `json:"consistent"`
+	Discrepancies   []*Discrepancy `json:"discrepancies"`
+}
+
+// GetSupply returns the minted, burned and circulating totals
+func (s *SmartContract) GetSupply(ctx contractapi.TransactionContextInterface) (*Supply, error) {
+	return getSupply(ctx)
+}
+
+// VerifyLedgerInvariants scans every wallet and TX_ record and reports where
+// the ledger breaks conservation: balances must be non-negative and sum to
+// minted minus burned, and the supply must agree with the mint and burn
+// records. Balances seeded by InitLedger have no mint record, so minted
+// records may fall short of the supply but never exceed it. Admin only.
+func (s *SmartContract) VerifyLedgerInvariants(ctx contractapi.TransactionContextInterface) (*LedgerReport, error) {
+	if _, err := requireAdmin(ctx, "VerifyLedgerInvariants"); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	supply, err := getSupply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &LedgerReport{CheckedAt: timestamp.Seconds, Supply: *supply, Discrepancies: []*Discrepancy{}}
+	addDiscrepancy := func(kind string, key string, expected int64, actual int64, format string, args ...interface{}) {
+		report.Discrepancies = append(report.Discrepancies, &Discrepancy{
+			Type: kind, Key: key, Expected: expected, Actual: actual, Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	// An empty range covers every simple key: wallets, TX_ records and ledger metadata
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		key := queryResponse.Key
+
+		if strings.HasPrefix(key, "TX_") {
+			var record TransactionRecord
+			if err := json.Unmarshal(queryResponse.Value, &record); err != nil {
+				addDiscrepancy(DiscrepancyInvalidRecord, key, 0, 0, "record cannot be decoded: %v", err)
+				continue
+			}
+			report.RecordCount++
+			if record.Amount <= 0 {
+				addDiscrepancy(DiscrepancyInvalidRecord, key, 0, record.Amount, "record amount is not positive")
+			}
+			// Other assets have their own supply, tracked on the token
+			if record.assetOf() != DefaultAsset {
+				continue
+			}
+			switch record.Type {
+			case "mint":
+				report.MintedInRecords += record.Amount
+			case "burn":
+				report.BurnedInRecords += record.Amount
+			}
+			continue
+		}
+		if isReservedKey(key) {
+			continue
+		}
+
+		var wallet UserWallet
+		if err := json.Unmarshal(queryResponse.Value, &wallet); err != nil {
+			return nil, fmt.Errorf("failed to decode wallet %s: %v", key, err)
+		}
+		report.WalletCount++
+		report.WalletTotal += wallet.Balance
+		if wallet.Balance < 0 {
+			addDiscrepancy(DiscrepancyNegativeBalance, key, 0, wallet.Balance, "wallet balance is negative")
+		} else if wallet.Held < 0 || wallet.Held > wallet.Balance {
+			addDiscrepancy(DiscrepancyInvalidHeld, key, wallet.Balance, wallet.Held, "held amount is outside 0..balance")
+		} else if granted := wallet.grantTotal(); granted > wallet.Balance {
+			addDiscrepancy(DiscrepancyInvalidGrants, key, wallet.Balance, granted, "grant lots exceed the balance")
+		}
+	}
+
+	if supply.Total != supply.Minted-supply.Burned {
+		addDiscrepancy(DiscrepancySupplyInconsistent, supplyKey, supply.Minted-supply.Burned, supply.Total, "supply total is not minted minus burned")
+	}
+	if report.WalletTotal != supply.Total {
+		addDiscrepancy(DiscrepancySupplyMismatch, supplyKey, supply.Total, report.WalletTotal, "wallet balances do not sum to the supply")
+	}
+	if report.MintedInRecords > supply.Minted {
+		addDiscrepancy(DiscrepancyMintedMismatch, supplyKey, supply.Minted, report.MintedInRecords, "mint records exceed the minted supply")
+	}
+	if report.BurnedInRecords != supply.Burned {
+		addDiscrepancy(DiscrepancyBurnedMismatch, supplyKey, supply.Burned, report.BurnedInRecords, "burn records do not match the burned supply")
+	}
+
+	report.Consistent = len(report.Discrepancies) == 0
+	return report, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	transferRuleObjectType = "transferrule"
+	// anyRole matches every wallet type in a transfer rule
+	anyRole = "*"
+)
+
+// TransferRule allows or denies payments from wallets of one type to wallets
+// of another. The most specific rule wins: (sender, receiver), then
+// (sender, *), then (*, receiver), then (*, *). Pairs without any matching
+// rule are allowed, so setting (*, *) to denied turns the matrix into an allow list.
+// Allowed applies to payments in every asset. MaxPerTransaction is a VAP
+// amount and caps VAP payments; amounts of registered assets are capped by
+// spending limits on that asset, see limits.go.
+type TransferRule struct {
+	SenderRole        string `json:"senderRole"`
+	ReceiverRole      string `json:"receiverRole"`
+	Allowed           bool   `json:"allowed"`
+	MaxPerTransaction int64  `json:"maxPerTransaction"` // minor units, zero means no pair limit
+	UpdatedAt         int64  `json:"updatedAt"`
+}
+
+// SetTransferRule creates or replaces the rule for a sender and receiver wallet type. Admin only.
+func (s *SmartContract) SetTransferRule(ctx contractapi.TransactionContextInterface, senderRole string, receiverRole string, allowed bool, maxPerTransaction int64) (*TransferRule, error) {
+	if _, err := requireAdmin(ctx, "SetTransferRule"); err != nil {
+		return nil, err
+	}
+	if senderRole == "" || receiverRole == "" {
+		return nil, fmt.Errorf("sender and receiver roles are required")
+	}
+	if maxPerTransaction < 0 {
+		return nil, fmt.Errorf("limits cannot be negative")
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	rule := &TransferRule{
+		SenderRole:        senderRole,
+		ReceiverRole:      receiverRole,
+		Allowed:           allowed,
+		MaxPerTransaction: maxPerTransaction,
+		UpdatedAt:         timestamp.Seconds,
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(transferRuleObjectType, []string{senderRole, receiverRole})
+	if err != nil {
+		return nil, err
+	}
+	ruleJSON, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+	return rule, ctx.GetStub().PutState(key, ruleJSON)
+}
+
+// DeleteTransferRule removes the rule for a pair so broader rules apply again. Admin only.
+func (s *SmartContract) DeleteTransferRule(ctx contractapi.TransactionContextInterface, senderRole string, receiverRole string) error {
+	if _, err := requireAdmin(ctx, "DeleteTransferRule"); err != nil {
+		return err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(transferRuleObjectType, []string{senderRole, receiverRole})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().DelState(key)
+}
+
+// GetTransferPolicy returns every configured transfer rule
+func (s *SmartContract) GetTransferPolicy(ctx contractapi.TransactionContextInterface) ([]*TransferRule, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transferRuleObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	rules := []*TransferRule{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var rule TransferRule
+		err = json.Unmarshal(response.Value, &rule)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+
+	return rules, nil
+}
+
+// checkTransferPolicy rejects a payment the matrix does not allow between the two wallet types
+func checkTransferPolicy(ctx contractapi.TransactionContextInterface, from *UserWallet, to *UserWallet, asset string, amount int64) error {
+	rule, err := getMatchingTransferRule(ctx, from.Type, to.Type)
+	if err != nil || rule == nil {
+		return err
+	}
+
+	if !rule.Allowed {
+		return newContractError(ErrCodeTransferNotAllowed, "%s wallets may not pay %s wallets", from.Type, to.Type)
+	}
+	if asset == DefaultAsset && rule.MaxPerTransaction > 0 && amount > rule.MaxPerTransaction {
+		return newContractError(ErrCodeLimitExceeded, "amount %d exceeds the limit of %d for %s to %s payments", amount, rule.MaxPerTransaction, from.Type, to.Type)
+	}
+	return nil
+}
+
+// getMatchingTransferRule returns the most specific rule for a pair, or nil
+func getMatchingTransferRule(ctx contractapi.TransactionContextInterface, senderRole string, receiverRole string) (*TransferRule, error) {
+	candidates := [][]string{
+		{senderRole, receiverRole},
+		{senderRole, anyRole},
+		{anyRole, receiverRole},
+		{anyRole, anyRole},
+	}
+	for _, pair := range candidates {
+		key, err := ctx.GetStub().CreateCompositeKey(transferRuleObjectType, pair)
+		if err != nil {
+			return nil, err
+		}
+		ruleJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if ruleJSON == nil {
+			continue
+		}
+
+		var rule TransferRule
+		err = json.Unmarshal(ruleJSON, &rule)
+		if err != nil {
+			return nil, err
+		}
+		return &rule, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// isReservedKey reports whether a simple key belongs to ledger metadata or
+// transaction records rather than to a wallet
+func isReservedKey(key string) bool {
+	return key == schemaVersionKey || key == supplyKey || strings.HasPrefix(key, "TX_")
+}
+
+// getWallet reads a wallet from world state
+func getWallet(ctx contractapi.TransactionContextInterface, id string) (*UserWallet, error) {
+	walletJSON, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if walletJSON == nil {
+		return nil, fmt.Errorf("the wallet %s does not exist", id)
+	}
+
+	var wallet UserWallet
+	err = json.Unmarshal(walletJSON, &wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	wallet.asOf = timestamp.Seconds
+	wallet.indexKeys, err = walletIndexKeys(ctx, &wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+// putWallet writes a wallet to world state
+func putWallet(ctx contractapi.TransactionContextInterface, wallet *UserWallet) error {
+	if err := requireMinorUnits(ctx); err != nil {
+		return err
+	}
+	walletJSON, err := json.Marshal(wallet)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(wallet.ID, walletJSON); err != nil {
+		return err
+	}
+	return updateWalletIndexes(ctx, wallet)
+}
+
+// walletIndexKeys returns the composite keys that should index a wallet:
+// its cohort, and one per grant lot by expiry
+func walletIndexKeys(ctx contractapi.TransactionContextInterface, wallet *UserWallet) ([]string, error) {
+	var keys []string
+	if wallet.Cohort != "" {
+		key, err := ctx.GetStub().CreateCompositeKey(cohortWalletIndex, []string{wallet.Cohort, wallet.ID})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	for _, lot := range wallet.Grants {
+		key, err := grantExpiryKey(ctx, wallet.ID, lot)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// updateWalletIndexes adds the index keys a wallet gained since it was read
+// and deletes the ones it lost
+func updateWalletIndexes(ctx contractapi.TransactionContextInterface, wallet *UserWallet) error {
+	keys, err := walletIndexKeys(ctx, wallet)
+	if err != nil {
+		return err
+	}
+
+	stale := make(map[string]bool, len(wallet.indexKeys))
+	for _, key := range wallet.indexKeys {
+		stale[key] = true
+	}
+	for _, key := range keys {
+		if stale[key] {
+			delete(stale, key)
+			continue
+		}
+		if err := ctx.GetStub().PutState(key, []byte{0x00}); err != nil {
+			return err
+		}
+	}
+	for _, key := range wallet.indexKeys {
+		if stale[key] {
+			if err := ctx.GetStub().DelState(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	wallet.indexKeys = keys
+	return nil
+}
+
+// ReindexWallets writes the wallet index keys for every wallet, for ledgers
+// upgraded from a version that did not keep them. Admin only.
+func (s *SmartContract) ReindexWallets(ctx contractapi.TransactionContextInterface) error {
+	if _, err := requireAdmin(ctx, "ReindexWallets"); err != nil {
+		return err
+	}
+
+	return forEachWallet(ctx, func(wallet *UserWallet) error {
+		keys, err := walletIndexKeys(ctx, wallet)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := ctx.GetStub().PutState(key, []byte{0x00}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// forEachWallet calls fn for every wallet in world state
+func forEachWallet(ctx contractapi.TransactionContextInterface, fn func(*UserWallet) error) error {
+	// An empty range covers every simple key; composite index keys are excluded
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+		if isReservedKey(queryResponse.Key) {
+			continue
+		}
+
+		var wallet UserWallet
+		err = json.Unmarshal(queryResponse.Value, &wallet)
+		if err != nil {
+			return fmt.Errorf("failed to decode wallet %s: %v", queryResponse.Key, err)
+		}
+		if err := fn(&wallet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ClosureResult is the outcome of CloseWallet
+type ClosureResult struct {
+	Wallet  *UserWallet          `json:"wallet"`
+	Records []*TransactionRecord `json:"records"`
+	Total   int64                `json:"total"` // VAP moved to the sweep wallet, in minor units
+}
+
+// CloseWallet closes a wallet for good, for instance when a student
+// graduates. Expired grant lots return to their treasuries as "expiry"
+// records; the rest of the balance and every other asset move to sweepTo as
+// "closure" records, except non-transferable assets, which return to their
+// issuer. Records are numbered "<txId>_<n>". A wallet with active holds
+// cannot be closed until they are captured or released, and the admin
+// wallet, fee and grant treasuries and token issuers cannot be closed at all
+// while they are referenced, since funds keep flowing into them. Neither can
+// the source of an active schedule until the schedule is cancelled. Admin only.
+func (s *SmartContract) CloseWallet(ctx contractapi.TransactionContextInterface, id string, sweepTo string) (*ClosureResult, error) {
+	c, err := requireAdmin(ctx, "CloseWallet")
+	if err != nil {
+		return nil, err
+	}
+	if id == sweepTo {
+		return nil, fmt.Errorf("cannot sweep a wallet into itself")
+	}
+
+	// Treasuries and issuers may be the sweep wallet, so every wallet is loaded once
+	wallets := newWalletCache(ctx)
+	wallet, err := wallets.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.CurrentStatus() == WalletStatusClosed {
+		return nil, newContractError(ErrCodeWalletClosed, "wallet %s is already closed", id)
+	}
+	if wallet.Held > 0 {
+		return nil, fmt.Errorf("wallet %s has %d minor units reserved by active holds", id, wallet.Held)
+	}
+	blocker, err := closureBlocker(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if blocker != "" {
+		return nil, fmt.Errorf("wallet %s cannot be closed while it is %s", id, blocker)
+	}
+	target, err := wallets.get(sweepTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := target.RequireActive(); err != nil {
+		return nil, err
+	}
+
+	result := &ClosureResult{Records: []*TransactionRecord{}}
+	txID := ctx.GetStub().GetTxID()
+	addRecord := func(to string, amount int64, txType string) (*TransactionRecord, error) {
+		record, err := newTransactionRecord(ctx, id, to, amount, txType)
+		if err != nil {
+			return nil, err
+		}
+		record.TxID = fmt.Sprintf("%s_%d", txID, len(result.Records)+1)
+		result.Records = append(result.Records, record)
+		return record, nil
+	}
+
+	for _, lot := range wallet.Grants {
+		if lot.ExpiresAt > wallet.asOf {
+			continue
+		}
+		treasury, err := wallets.get(lot.Treasury)
+		if err != nil {
+			return nil, err
+		}
+		if err := treasury.RequireActive(); err != nil {
+			return nil, err
+		}
+		wallet.Balance -= lot.Amount
+		treasury.Balance, err = addAmount(treasury.Balance, lot.Amount)
+		if err != nil {
+			return nil, err
+		}
+		record, err := addRecord(treasury.ID, lot.Amount, "expiry")
+		if err != nil {
+			return nil, err
+		}
+		record.GrantTxID = lot.TxID
+	}
+	wallet.Grants = nil
+
+	if wallet.Balance > 0 {
+		target.Balance, err = addAmount(target.Balance, wallet.Balance)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := addRecord(sweepTo, wallet.Balance, "closure"); err != nil {
+			return nil, err
+		}
+		result.Total = wallet.Balance
+	}
+	wallet.Balance = 0
+
+	balances, err := getAssetBalances(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, balance := range balances {
+		token, err := resolveAsset(ctx, balance.Asset)
+		if err != nil {
+			return nil, err
+		}
+		recipient := sweepTo
+		if !token.Transferable && token.Issuer != id {
+			issuer, err := wallets.get(token.Issuer)
+			if err != nil {
+				return nil, err
+			}
+			if err := issuer.RequireActive(); err != nil {
+				return nil, err
+			}
+			recipient = issuer.ID
+		}
+
+		if err := creditAsset(ctx, recipient, balance.Asset, balance.Balance); err != nil {
+			return nil, err
+		}
+		record, err := addRecord(recipient, balance.Balance, "closure")
+		if err != nil {
+			return nil, err
+		}
+		record.Asset = balance.Asset
+		balance.Balance = 0
+		if err := putAssetBalance(ctx, balance); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, record := range result.Records {
+		if err := putTransactionRecord(ctx, record); err != nil {
+			return nil, err
+		}
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	wallet.Status = WalletStatusClosed
+	wallet.StatusReason = ""
+	wallet.StatusNote = fmt.Sprintf("balance swept to %s", sweepTo)
+	wallet.StatusChangedBy = c.ID
+	wallet.StatusChangedAt = timestamp.Seconds
+	if err := wallets.putAll(); err != nil {
+		return nil, err
+	}
+
+	result.Wallet = wallet
+	return result, emitEvent(ctx, EventWalletClosed, LedgerEvent{Wallet: wallet, Records: result.Records})
+}
+
+// closureBlocker describes what still credits or debits a wallet: VAP is
+// minted into the admin wallet, fee policies and other wallets' grant lots
+// name their treasury, non-transferable tokens return to their issuer, and
+// active schedules pay from their source. It returns "" when nothing does.
+func closureBlocker(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	if id == "admin" {
+		return "the admin wallet", nil
+	}
+
+	policies, err := ctx.GetStub().GetStateByPartialCompositeKey(feePolicyObjectType, []string{})
+	if err != nil {
+		return "", err
+	}
+	defer policies.Close()
+	for policies.HasNext() {
+		response, err := policies.Next()
+		if err != nil {
+			return "", err
+		}
+		var policy FeePolicy
+		if err := json.Unmarshal(response.Value, &policy); err != nil {
+			return "", err
+		}
+		if policy.TreasuryID == id {
+			return fmt.Sprintf("the fee treasury of category %s", policy.Category), nil
+		}
+	}
+
+	tokens, err := ctx.GetStub().GetStateByPartialCompositeKey(tokenObjectType, []string{})
+	if err != nil {
+		return "", err
+	}
+	defer tokens.Close()
+	for tokens.HasNext() {
+		response, err := tokens.Next()
+		if err != nil {
+			return "", err
+		}
+		var token Token
+		if err := json.Unmarshal(response.Value, &token); err != nil {
+			return "", err
+		}
+		if token.Issuer == id {
+			return fmt.Sprintf("the issuer of %s", token.Symbol), nil
+		}
+	}
+
+	schedules, err := ctx.GetStub().GetStateByPartialCompositeKey(scheduleObjectType, []string{})
+	if err != nil {
+		return "", err
+	}
+	defer schedules.Close()
+	for schedules.HasNext() {
+		response, err := schedules.Next()
+		if err != nil {
+			return "", err
+		}
+		var schedule Schedule
+		if err := json.Unmarshal(response.Value, &schedule); err != nil {
+			return "", err
+		}
+		if schedule.Status == ScheduleStatusActive && schedule.Source == id {
+			return fmt.Sprintf("the source of schedule %s", schedule.ID), nil
+		}
+	}
+
+	lots, err := ctx.GetStub().GetStateByPartialCompositeKey(grantExpiryIndex, []string{})
+	if err != nil {
+		return "", err
+	}
+	defer lots.Close()
+	checked := map[string]bool{id: true}
+	for lots.HasNext() {
+		response, err := lots.Next()
+		if err != nil {
+			return "", err
+		}
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(response.Key)
+		if err != nil {
+			return "", err
+		}
+		if len(compositeKeyParts) < 3 || checked[compositeKeyParts[1]] {
+			continue
+		}
+		checked[compositeKeyParts[1]] = true
+
+		wallet, err := getWallet(ctx, compositeKeyParts[1])
+		if err != nil {
+			return "", err
+		}
+		for _, lot := range wallet.Grants {
+			if lot.Treasury == id {
+				return fmt.Sprintf("the treasury of grant %s to %s", lot.TxID, wallet.ID), nil
+			}
+		}
+	}
+
+	return "", nil
+}
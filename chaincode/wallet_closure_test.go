@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClosureBlockers(t *testing.T) {
+	student := testIdentity{role: "student", wallet: "student1"}
+	tests := []struct {
+		name        string
+		wallet      string
+		setup       func(l *testLedger, s *SmartContract) error
+		wantBlocker string
+	}{
+		{"admin wallet", "admin", nil, "the admin wallet"},
+		{"active hold", "student1", func(l *testLedger, s *SmartContract) error {
+			_, err := s.PlaceHold(l.tx(student), "student1", "merchant1", 1000, "order-1", l.now+3600)
+			return err
+		}, "reserved by active holds"},
+		{"fee treasury", "student1", func(l *testLedger, s *SmartContract) error {
+			_, err := s.SetFeePolicy(l.admin(), defaultFeeCategory, 100, 0, 0, 0, "student1")
+			return err
+		}, "the fee treasury of category default"},
+		{"token issuer", "student1", func(l *testLedger, s *SmartContract) error {
+			_, err := s.RegisterToken(l.admin(), "MEAL", "Meal credits", 0, "student1", false)
+			return err
+		}, "the issuer of MEAL"},
+		{"grant treasury", "student1", func(l *testLedger, s *SmartContract) error {
+			_, err := s.Grant(l.admin(), "student1", "student2", 1000, l.now+3600)
+			return err
+		}, "the treasury of grant"},
+		{"schedule source", "student1", func(l *testLedger, s *SmartContract) error {
+			_, err := s.CreateSchedule(l.admin(), "allowance", "student1", []string{"student2"}, "", 100, "daily", l.now+3600)
+			return err
+		}, "the source of schedule allowance"},
+		{"schedule cancelled", "student1", func(l *testLedger, s *SmartContract) error {
+			if _, err := s.CreateSchedule(l.admin(), "allowance", "student1", []string{"student2"}, "", 100, "daily", l.now+3600); err != nil {
+				return err
+			}
+			_, err := s.CancelSchedule(l.admin(), "allowance")
+			return err
+		}, ""},
+		{"nothing", "student1", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, s := newSeededLedger(t)
+			if err := s.CreateWallet(l.admin(), "student2", "student"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				if err := tt.setup(l, s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			balance := l.wallet(tt.wallet).Balance
+
+			result, err := s.CloseWallet(l.admin(), tt.wallet, "student2")
+			if tt.wantBlocker != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantBlocker) {
+					t.Fatalf("CloseWallet error = %v, want it blocked by %q", err, tt.wantBlocker)
+				}
+				if status := l.wallet(tt.wallet).CurrentStatus(); status != WalletStatusActive {
+					t.Errorf("status = %s after a blocked closure, want active", status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Total != balance || l.wallet(tt.wallet).Balance != 0 || l.wallet(tt.wallet).CurrentStatus() != WalletStatusClosed {
+				t.Errorf("closure = %+v, want %d swept and the wallet closed", result, balance)
+			}
+			if got := l.wallet("student2").Balance; got != balance {
+				t.Errorf("student2 balance = %d, want %d", got, balance)
+			}
+			if _, err := s.CloseWallet(l.admin(), tt.wallet, "student2"); errorCode(err) != ErrCodeWalletClosed {
+				t.Errorf("second closure error = %v, want %s", err, ErrCodeWalletClosed)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	maxDisplayNameLength = 64
+	maxLocationLength    = 128
+	maxLogoRefLength     = 256
+)
+
+// GetWallet returns the full wallet, including its public profile
+func (s *SmartContract) GetWallet(ctx contractapi.TransactionContextInterface, id string) (*UserWallet, error) {
+	walletJSON, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	// Transaction records and ledger metadata share the key space with wallets
+	if walletJSON == nil || isReservedKey(id) {
+		return nil, newContractError(ErrCodeNotFound, "wallet %s does not exist", id)
+	}
+	return getWallet(ctx, id)
+}
+
+// WalletMetadataChanges are the profile fields an UpdateWalletMetadata
+// changes. Omitted fields keep their value; an empty string clears a field.
+type WalletMetadataChanges struct {
+	DisplayName *string `json:"displayName,omitempty"`
+	Location    *string `json:"location,omitempty"`
+	LogoRef     *string `json:"logoRef,omitempty"`
+	Department  *string `json:"department,omitempty"`
+}
+
+// UpdateWalletMetadata applies changesJSON, a JSON WalletMetadataChanges, to
+// the public profile of a wallet. Only the fields present change, so
+// concurrent edits of different fields do not undo each other. Merchants may
+// set a location and logo, students a department. The merchant category
+// selects the fee policy and is set with SetMerchantCategory only. Owner or
+// admin only.
+func (s *SmartContract) UpdateWalletMetadata(ctx contractapi.TransactionContextInterface, walletID string, changesJSON string) (*UserWallet, error) {
+	if _, err := requireWalletAccess(ctx, walletID); err != nil {
+		return nil, err
+	}
+
+	var changes WalletMetadataChanges
+	decoder := json.NewDecoder(strings.NewReader(changesJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&changes); err != nil {
+		return nil, fmt.Errorf("invalid metadata changes: %v", err)
+	}
+	fields := []struct {
+		name      string
+		value     *string
+		maxLength int
+	}{
+		{"displayName", changes.DisplayName, maxDisplayNameLength},
+		{"location", changes.Location, maxLocationLength},
+		{"logoRef", changes.LogoRef, maxLogoRefLength},
+		{"department", changes.Department, maxReferenceLength},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		}
+		if err := validateText(field.name, *field.value, field.maxLength); err != nil {
+			return nil, err
+		}
+	}
+
+	wallet, err := getWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.CurrentStatus() == WalletStatusClosed {
+		return nil, newContractError(ErrCodeWalletClosed, "wallet %s is closed", walletID)
+	}
+	if wallet.Type != "merchant" && (isSet(changes.Location) || isSet(changes.LogoRef)) {
+		return nil, fmt.Errorf("only merchant wallets have a location or logo, %s is a %s wallet", walletID, wallet.Type)
+	}
+	if wallet.Type != "student" && isSet(changes.Department) {
+		return nil, fmt.Errorf("only student wallets have a department, %s is a %s wallet", walletID, wallet.Type)
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	setIfPresent(&wallet.DisplayName, changes.DisplayName)
+	setIfPresent(&wallet.Location, changes.Location)
+	setIfPresent(&wallet.LogoRef, changes.LogoRef)
+	setIfPresent(&wallet.Department, changes.Department)
+	wallet.MetadataUpdatedAt = timestamp.Seconds
+	if err := putWallet(ctx, wallet); err != nil {
+		return nil, err
+	}
+
+	return wallet, emitEvent(ctx, EventWalletUpdated, LedgerEvent{Wallet: wallet})
+}
+
+// isSet reports whether a change sets a non-empty value
+func isSet(value *string) bool {
+	return value != nil && *value != ""
+}
+
+// setIfPresent copies a change into field, leaving it alone when omitted
+func setIfPresent(field *string, value *string) {
+	if value != nil {
+		*field = *value
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Wallet statuses
+const (
+	WalletStatusActive = "active"
+	WalletStatusFrozen = "frozen"
+	WalletStatusClosed = "closed"
+)
+
+// freezeReasons lists the reason codes accepted by FreezeWallet
+var freezeReasons = map[string]bool{
+	"lost_device":     true,
+	"suspected_fraud": true,
+	"compliance":      true,
+	"user_request":    true,
+	"other":           true,
+}
+
+const maxStatusNoteLength = 256
+
+// CurrentStatus returns the wallet status, defaulting to active for legacy wallets
+func (w *UserWallet) CurrentStatus() string {
+	if w.Status == "" {
+		return WalletStatusActive
+	}
+	return w.Status
+}
+
+// RequireActive returns a typed error if the wallet cannot send or receive funds
+func (w *UserWallet) RequireActive() error {
+	switch w.CurrentStatus() {
+	case WalletStatusActive:
+		return nil
+	case WalletStatusFrozen:
+		return newContractError(ErrCodeWalletFrozen, "wallet %s is frozen (%s)", w.ID, w.StatusReason)
+	case WalletStatusClosed:
+		return newContractError(ErrCodeWalletClosed, "wallet %s is closed", w.ID)
+	default:
+		return fmt.Errorf("wallet %s has unknown status %q", w.ID, w.Status)
+	}
+}
+
+// FreezeWallet blocks all transfers from and to a wallet. Admin only.
+func (s *SmartContract) FreezeWallet(ctx contractapi.TransactionContextInterface, id string, reasonCode string, note string) error {
+	c, err := requireAdmin(ctx, "FreezeWallet")
+	if err != nil {
+		return err
+	}
+	if !freezeReasons[reasonCode] {
+		return fmt.Errorf("unknown freeze reason code %q", reasonCode)
+	}
+
+	wallet, err := getWallet(ctx, id)
+	if err != nil {
+		return err
+	}
+	if wallet.CurrentStatus() != WalletStatusActive {
+		return fmt.Errorf("wallet %s is %s and cannot be frozen", id, wallet.CurrentStatus())
+	}
+
+	return setWalletStatus(ctx, c, wallet, WalletStatusFrozen, reasonCode, note, EventWalletFrozen)
+}
+
+// UnfreezeWallet restores a frozen wallet to active. Admin only.
+func (s *SmartContract) UnfreezeWallet(ctx contractapi.TransactionContextInterface, id string, note string) error {
+	c, err := requireAdmin(ctx, "UnfreezeWallet")
+	if err != nil {
+		return err
+	}
+
+	wallet, err := getWallet(ctx, id)
+	if err != nil {
+		return err
+	}
+	if wallet.CurrentStatus() != WalletStatusFrozen {
+		return fmt.Errorf("wallet %s is not frozen", id)
+	}
+
+	return setWalletStatus(ctx, c, wallet, WalletStatusActive, "", note, EventWalletUnfrozen)
+}
+
+// setWalletStatus records the new status along with who changed it and why.
+// Earlier changes remain visible through GetHistory.
+func setWalletStatus(ctx contractapi.TransactionContextInterface, c *caller, wallet *UserWallet, status string, reasonCode string, note string, event string) error {
+	if len(note) > maxStatusNoteLength {
+		return fmt.Errorf("note must be at most %d characters", maxStatusNoteLength)
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	wallet.Status = status
+	wallet.StatusReason = reasonCode
+	wallet.StatusNote = note
+	wallet.StatusChangedBy = c.ID
+	wallet.StatusChangedAt = timestamp.Seconds
+
+	if err := putWallet(ctx, wallet); err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, event, LedgerEvent{Wallet: wallet})
+}
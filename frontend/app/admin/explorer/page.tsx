@@ -13,7 +13,7 @@ interface TransactionRecord {
   txId: string
   from: string
   to: string
-  amount: number
+  amount: string
   timestamp: number
   type: string
 }
@@ -70,7 +70,7 @@ export default function ExplorerPage() {
       tx.txId.toLowerCase().includes(query) ||
       tx.from.toLowerCase().includes(query) ||
       tx.to.toLowerCase().includes(query) ||
-      tx.amount.toString().includes(query)
+      tx.amount.includes(query)
     )
   })
 
@@ -26,7 +26,7 @@ interface User {
 
 export default function AdminDashboard() {
   const [user, setUser] = useState<User | null>(null);
-  const [balance, setBalance] = useState<number>(0);
+  const [balance, setBalance] = useState<string>("0.00");
   const [loading, setLoading] = useState(false);
   const [transactions, setTransactions] = useState<any[]>([]);
   const [bookmark, setBookmark] = useState("");
@@ -39,6 +39,7 @@ export default function AdminDashboard() {
 
   // Mint State
   const [mintAmount, setMintAmount] = useState("");
+  const [mintReason, setMintReason] = useState("");
   const [isMintOpen, setIsMintOpen] = useState(false);
 
   // Restore State
@@ -111,24 +112,24 @@ export default function AdminDashboard() {
     if (!user) return;
     setLoading(true);
     try {
-      const res = await fetch(`${process.env.NEXT_PUBLIC_API_URL}/mint`, {
+      // Minting needs the approvers' sign-off, so this only opens a proposal
+      const res = await fetch(`${process.env.NEXT_PUBLIC_API_URL}/mint-proposals`, {
         method: "POST",
         headers: { 
             "Content-Type": "application/json",
             "Authorization": `Bearer ${user.token}`
         },
-        body: JSON.stringify({ amount: parseFloat(mintAmount) }),
+        body: JSON.stringify({ amount: mintAmount, reason: mintReason }),
       });
 
-      if (!res.ok) throw new Error("Mint failed");
+      if (!res.ok) throw new Error("Mint proposal failed");
 
-      toast.success(`Successfully minted ${mintAmount} VapCoins`);
+      toast.success(`Proposed minting ${mintAmount} VapCoins, awaiting approval`);
       setMintAmount("");
+      setMintReason("");
       setIsMintOpen(false);
-      fetchBalance(user.username, user.token);
-      fetchTransactions(user.token);
     } catch (error) {
-      toast.error("Minting failed");
+      toast.error("Mint proposal failed");
     } finally {
       setLoading(false);
     }
@@ -149,7 +150,7 @@ export default function AdminDashboard() {
         body: JSON.stringify({
           from: user.username,
           to: recipient,
-          amount: parseFloat(transferAmount),
+          amount: transferAmount,
         }),
       });
 
@@ -281,7 +282,7 @@ export default function AdminDashboard() {
             <DialogContent className="sm:max-w-md">
               <DialogHeader>
                 <DialogTitle>Mint New Coins</DialogTitle>
-                <DialogDescription>Create new VapCoins and add to Admin Reserve.</DialogDescription>
+                <DialogDescription>Propose new VapCoins for the Admin Reserve. They are minted once enough approvers sign off.</DialogDescription>
               </DialogHeader>
               <form onSubmit={handleMint} className="space-y-4 mt-4">
                 <div className="space-y-2">
@@ -296,8 +297,18 @@ export default function AdminDashboard() {
                     required
                   />
                 </div>
+                <div className="space-y-2">
+                  <Label htmlFor="mintReason">Reason</Label>
+                  <Input 
+                    id="mintReason" 
+                    value={mintReason} 
+                    onChange={(e) => setMintReason(e.target.value)} 
+                    placeholder="Semester top-up"
+                    required
+                  />
+                </div>
                 <Button type="submit" className="w-full bg-emerald-600 hover:bg-emerald-700" disabled={loading}>
-                  {loading ? "Minting..." : "Confirm Mint"}
+                  {loading ? "Proposing..." : "Propose Mint"}
                 </Button>
               </form>
             </DialogContent>
@@ -27,7 +27,7 @@ interface User {
 
 export default function MerchantDashboard() {
   const [user, setUser] = useState<User | null>(null);
-  const [balance, setBalance] = useState<number>(0);
+  const [balance, setBalance] = useState<string>("0.00");
   const [history, setHistory] = useState<any[]>([]);
   const [bookmark, setBookmark] = useState("");
   const [hasMore, setHasMore] = useState(false);
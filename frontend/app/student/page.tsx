@@ -28,7 +28,7 @@ interface User {
 
 export default function StudentDashboard() {
   const [user, setUser] = useState<User | null>(null);
-  const [balance, setBalance] = useState<number>(0);
+  const [balance, setBalance] = useState<string>("0.00");
   const [loading, setLoading] = useState(false);
   const [transferAmount, setTransferAmount] = useState("");
   const [recipient, setRecipient] = useState("");
@@ -114,7 +114,7 @@ export default function StudentDashboard() {
         body: JSON.stringify({
           from: user.username,
           to: recipient,
-          amount: parseFloat(transferAmount),
+          amount: transferAmount,
         }),
       });
 
@@ -13,7 +13,7 @@ interface TransactionRecord {
   txId: string
   from: string
   to: string
-  amount: number
+  amount: string
   timestamp: number
   type: string
 }
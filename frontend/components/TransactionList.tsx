@@ -12,7 +12,7 @@ interface TransactionRecord {
   txId: string
   from: string
   to: string
-  amount: number
+  amount: string
   timestamp: number
   type: string
 }
@@ -37,7 +37,7 @@ export function TransactionList({ transactions, role, username, loading, onLoadM
       tx.txId.toLowerCase().includes(query) ||
       tx.from.toLowerCase().includes(query) ||
       tx.to.toLowerCase().includes(query) ||
-      tx.amount.toString().includes(query)
+      tx.amount.includes(query)
     )
   })
 
@@ -0,0 +1,54 @@
+#!/bin/bash
+
+# Lets a mint approver vote with their own credentials. The backend signs as
+# a single identity, so it can cast at most one of the approvals a mint needs;
+# every other approver votes here with the MSP directory of their own admin
+# identity (an admin certificate of Org1, or one enrolled with the
+# vapcoin.role=admin attribute).
+#
+# Usage:
+#   ./mint_vote.sh <msp-dir> id                      print the ID to register with SetMintApprovers
+#   ./mint_vote.sh <msp-dir> approve <proposalId>
+#   ./mint_vote.sh <msp-dir> reject <proposalId> [reason]
+#
+# <msp-dir> is relative to network/crypto-config, e.g.
+#   peerOrganizations/org1.example.com/users/Admin@org1.example.com/msp
+
+set -e
+
+CC_NAME="vapcoin"
+CRYPTO_PATH="//opt/gopath/src/github.com/hyperledger/fabric/peer/crypto"
+
+if [ $# -lt 2 ]; then
+    sed -n '9,12p' "$0"
+    exit 1
+fi
+
+MSP_DIR="${CRYPTO_PATH}/$1"
+ACTION="$2"
+PROPOSAL_ID="$3"
+REASON="$4"
+
+case "$ACTION" in
+    id)
+        docker exec -e CORE_PEER_MSPCONFIGPATH=${MSP_DIR} cli peer chaincode query -C mychannel -n ${CC_NAME} -c '{"function":"GetCallerID","Args":[]}'
+        exit 0
+        ;;
+    approve)
+        ARGS="{\"function\":\"ApproveMint\",\"Args\":[\"${PROPOSAL_ID}\"]}"
+        ;;
+    reject)
+        ARGS="{\"function\":\"RejectMint\",\"Args\":[\"${PROPOSAL_ID}\",\"${REASON}\"]}"
+        ;;
+    *)
+        echo "Unknown action: ${ACTION}"
+        exit 1
+        ;;
+esac
+
+if [ -z "$PROPOSAL_ID" ]; then
+    echo "Error: proposal ID is required."
+    exit 1
+fi
+
+docker exec -e CORE_PEER_MSPCONFIGPATH=${MSP_DIR} cli peer chaincode invoke -o orderer.example.com:7050 --ordererTLSHostnameOverride orderer.example.com --tls --cafile ${CRYPTO_PATH}/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem -C mychannel -n ${CC_NAME} --peerAddresses peer0.org1.example.com:7051 --tlsRootCertFiles ${CRYPTO_PATH}/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt -c "${ARGS}" --waitForEvent